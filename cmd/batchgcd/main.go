@@ -0,0 +1,105 @@
+// Command batchgcd scans RSA public keys for shared prime factors.
+//
+//	batchgcd [-out dir] path...
+//
+// Every path is a key file or a directory walked for key files: PEM (PKCS#1,
+// PKIX, certificates), OpenSSH authorized_keys / .pub, JWK or JWK Sets.
+// Keys sharing a prime with another key of the corpus and moduli occurring
+// more than once are reported, and the private keys rebuilt from the shared primes are written to -out as PEM.
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	simplersa "simple-rsa/lib-simplersa"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", "", "directory for the PEM of the rebuilt private keys")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: batchgcd [-out dir] path...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var keys []*simplersa.PublicKey
+	var sources []string
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			parsed, err := simplersa.ParsePublicKeys(data)
+			if err != nil {
+				log.Printf("%s: %s", path, err)
+				return nil
+			}
+			for i, key := range parsed {
+				keys = append(keys, key)
+				sources = append(sources, fmt.Sprintf("%s#%d", path, i))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	found, duplicates, err := simplersa.FindSharedFactors(keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d keys, %d share primes with other keys, %d moduli occur more than once\n", len(keys), len(found), len(duplicates))
+	for _, d := range duplicates {
+		names := make([]string, len(d.Indices))
+		for i, index := range d.Indices {
+			names[i] = sources[index]
+		}
+		fmt.Printf("same %d-bit N: %s\n", d.N.BitLen(), strings.Join(names, ", "))
+	}
+	for _, f := range found {
+		fmt.Printf("%s: %d-bit N, shared factor %d bits", sources[f.Index], f.Key.N.BitLen(), f.Factor.BitLen())
+		if f.Private == nil {
+			fmt.Println(", not fully factored")
+			continue
+		}
+		fmt.Printf(", %d primes, private key rebuilt\n", len(f.Private.Primes))
+		if *out != "" {
+			if err := writeKey(*out, f.Index, f.Private); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// writeKey writes priv as a PKCS#1 PEM file named after its index
+func writeKey(dir string, index int, priv *simplersa.PrivateKey) error {
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: priv.N, E: priv.E},
+		D:         priv.D,
+		Primes:    priv.Primes,
+	}
+	key.Precompute()
+	der := x509.MarshalPKCS1PrivateKey(key)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("key-%d.pem", index))
+	return os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}), 0600)
+}
@@ -1,6 +1,6 @@
 module simple-rsa
 
-go 1.17
+go 1.24
 
 require github.com/zserge/lorca v0.1.10
 
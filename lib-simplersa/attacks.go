@@ -0,0 +1,365 @@
+package lib_simplersa
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+var (
+	errWiener         = errors.New("simple_rsa: D is not below Wiener's bound")
+	errHastad         = errors.New("simple_rsa: Håstad's attack needs E ciphertexts of one message under pairwise coprime moduli with the same E")
+	errCommonModulus  = errors.New("simple_rsa: the common modulus attack needs one N and coprime exponents")
+	errBleichenbacher = errors.New("simple_rsa: the padding oracle attack did not converge")
+	errManger         = errors.New("simple_rsa: Manger's attack needs 2B < N and a ciphertext of m < B")
+)
+
+// WienerAttack recovers D, p and q of N from a large public exponent e when
+// D < N^(1/4) / 3, with the continued fraction of e / N: one of its
+// convergents is k / D with E·D - 1 = k·φ(N). PublicKey keeps E in an int,
+// so keys of this library never qualify, e comes from imported keys.
+func WienerAttack(n, e *big.Int) (d, p, q *big.Int, err error) {
+	// convergents h / k of e / n, h_i = a_i h_i-1 + h_i-2
+	h0, h1 := big.NewInt(0), big.NewInt(1)
+	k0, k1 := big.NewInt(1), big.NewInt(0)
+	num, den := new(big.Int).Set(e), new(big.Int).Set(n)
+	a, r := new(big.Int), new(big.Int)
+	phi, s, disc, root := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	for den.Sign() != 0 {
+		a.QuoRem(num, den, r)
+		num, den = den, new(big.Int).Set(r)
+		h0, h1 = h1, new(big.Int).Add(new(big.Int).Mul(a, h1), h0)
+		k0, k1 = k1, new(big.Int).Add(new(big.Int).Mul(a, k1), k0)
+
+		// h / k = k' / d for E·d - 1 = k'·φ
+		kk, dd := h1, k1
+		if kk.Sign() == 0 || dd.Bit(0) == 0 {
+			continue
+		}
+		phi.Mul(e, dd).Sub(phi, bigOne)
+		if new(big.Int).Mod(phi, kk).Sign() != 0 {
+			continue
+		}
+		phi.Quo(phi, kk)
+		// p + q = N - φ + 1, p and q are the roots of x^2 - (p + q) x + N
+		s.Sub(n, phi).Add(s, bigOne)
+		disc.Mul(s, s).Sub(disc, new(big.Int).Lsh(n, 2))
+		if disc.Sign() < 0 {
+			continue
+		}
+		if root.Sqrt(disc); new(big.Int).Mul(root, root).Cmp(disc) != 0 {
+			continue
+		}
+		p = new(big.Int).Add(s, root)
+		q = new(big.Int).Sub(s, root)
+		p.Rsh(p, 1)
+		q.Rsh(q, 1)
+		if q.Sign() > 0 && new(big.Int).Mul(p, q).Cmp(n) == 0 {
+			return new(big.Int).Set(dd), p, q, nil
+		}
+	}
+	return nil, nil, nil, errWiener
+}
+
+// HastadBroadcast recovers m from E raw encryptions c_i = m^E mod N_i of the
+// same message, as done by encrypt without padding: by the CRT m^E is known
+// mod ∏N_i > m^E, the integer E-th root of it is m
+func HastadBroadcast(pubs []*PublicKey, cs []*big.Int) (*big.Int, error) {
+	if len(pubs) == 0 || len(pubs) != len(cs) || pubs[0].E < 2 || len(pubs) < pubs[0].E {
+		return nil, errHastad
+	}
+	e := pubs[0].E
+	pubs, cs = pubs[:e], cs[:e]
+
+	product := new(big.Int).Set(bigOne)
+	for _, pub := range pubs {
+		if pub.E != e {
+			return nil, errHastad
+		}
+		product.Mul(product, pub.N)
+	}
+	x := new(big.Int)
+	for i, pub := range pubs {
+		mi := new(big.Int).Quo(product, pub.N)
+		inv := modMultiInverse(mi, pub.N)
+		if inv == nil {
+			return nil, errHastad
+		}
+		x.Add(x, inv.Mul(inv, mi).Mul(inv, cs[i]))
+	}
+	x.Mod(x, product)
+
+	m := iroot(x, e)
+	if new(big.Int).Exp(m, big.NewInt(int64(e)), nil).Cmp(x) != 0 {
+		return nil, errHastad
+	}
+	return m, nil
+}
+
+// iroot returns the integer e-th root floor(x^(1/e)) with Newton's method
+func iroot(x *big.Int, e int) *big.Int {
+	if x.Sign() == 0 {
+		return new(big.Int)
+	}
+	bigE, eMinus1 := big.NewInt(int64(e)), big.NewInt(int64(e-1))
+	// start above the root: 2^ceil(bits / e)
+	y := new(big.Int).Lsh(bigOne, uint((x.BitLen()+e-1)/e))
+	t := new(big.Int)
+	for {
+		// y' = ((e - 1) y + x / y^(e-1)) / e
+		t.Exp(y, eMinus1, nil)
+		t.Quo(x, t)
+		t.Add(t, new(big.Int).Mul(eMinus1, y))
+		t.Quo(t, bigE)
+		if t.Cmp(y) >= 0 {
+			return y
+		}
+		y.Set(t)
+	}
+}
+
+// CommonModulusAttack recovers m from c1 = m^e1 and c2 = m^e2 mod one N with
+// gcd(e1, e2) = 1: with a·e1 + b·e2 = 1, m = c1^a · c2^b mod N
+func CommonModulusAttack(pub1, pub2 *PublicKey, c1, c2 *big.Int) (*big.Int, error) {
+	if pub1.N.Cmp(pub2.N) != 0 {
+		return nil, errCommonModulus
+	}
+	var a, b big.Int
+	e1, e2 := big.NewInt(int64(pub1.E)), big.NewInt(int64(pub2.E))
+	if exGcd(e1, e2, &a, &b).Cmp(bigOne) != 0 {
+		return nil, errCommonModulus
+	}
+	n := pub1.N
+	pow := func(c, x *big.Int) *big.Int {
+		if x.Sign() < 0 {
+			if c = modMultiInverse(c, n); c == nil {
+				return nil
+			}
+			x = new(big.Int).Neg(x)
+		}
+		return new(big.Int).Exp(c, x, n)
+	}
+	m1, m2 := pow(c1, &a), pow(c2, &b)
+	if m1 == nil || m2 == nil {
+		return nil, errCommonModulus
+	}
+	return m1.Mul(m1, m2).Mod(m1, n), nil
+}
+
+// PaddingOracle leaks one bit of the plaintext of a ciphertext: whether it
+// is a conforming EME-PKCS1-v1_5 block for Bleichenbacher's attack, whether
+// its first octet is zero for Manger's
+type PaddingOracle func(ciphertext []byte) bool
+
+// LeakyPKCS1v15Oracle is the oracle of a server that answers differently
+// when DecryptPKCS1v15 fails, the mistake Bleichenbacher's attack exploits
+func LeakyPKCS1v15Oracle(priv *PrivateKey) PaddingOracle {
+	return func(ciphertext []byte) bool {
+		_, err := DecryptPKCS1v15(nil, priv, ciphertext)
+		return err == nil
+	}
+}
+
+// interval is the closed interval [a, b]
+type interval struct {
+	a, b *big.Int
+}
+
+// ceilDiv returns ceil(x / y) for y > 0
+func ceilDiv(x, y *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(x, y, new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, bigOne)
+	}
+	return q
+}
+
+// BleichenbacherAttack decrypts a ciphertext with padding oracle queries
+// (Bleichenbacher, CRYPTO '98): every s with c·s^E conforming narrows m·s
+// mod N to [2B, 3B), B = 2^(8(k - 2)), until one candidate of m is left.
+// It returns the k-byte block m = c^D, an EME-PKCS1-v1_5 block unless c
+// had to be blinded first, and the number of queries.
+func BleichenbacherAttack(random io.Reader, pub *PublicKey, ciphertext []byte, oracle PaddingOracle) (em []byte, queries int, err error) {
+	k := pub.Size()
+	n, e := pub.N, big.NewInt(int64(pub.E))
+	if len(ciphertext) != k || k < 11 {
+		return nil, 0, ErrDecryption
+	}
+	B := new(big.Int).Lsh(bigOne, uint(8*(k-2)))
+	B2, B3 := new(big.Int).Lsh(B, 1), new(big.Int).Mul(B, big.NewInt(3))
+	B3m1 := new(big.Int).Sub(B3, bigOne)
+
+	c0 := new(big.Int).SetBytes(ciphertext)
+	buf := make([]byte, k)
+	// try reports whether c0·s^E is conforming
+	try := func(s *big.Int) bool {
+		queries++
+		x := new(big.Int).Exp(s, e, n)
+		x.Mul(x, c0).Mod(x, n)
+		return oracle(x.FillBytes(buf))
+	}
+
+	// 1. blinding: s0 with c0·s0^E conforming, 1 for a conforming c
+	s0 := big.NewInt(1)
+	if !try(s0) {
+		for {
+			if s0, err = randomBelow(random, n); err != nil {
+				return nil, queries, err
+			}
+			if s0.Sign() != 0 && try(s0) {
+				break
+			}
+		}
+		c0.Mul(c0, new(big.Int).Exp(s0, e, n)).Mod(c0, n)
+	}
+
+	M := []interval{{new(big.Int).Set(B2), new(big.Int).Set(B3m1)}}
+	s := ceilDiv(n, B3)
+	for i := 1; ; i++ {
+		switch {
+		case i == 1:
+			// 2.a. the smallest s >= N / 3B
+			for !try(s) {
+				s.Add(s, bigOne)
+			}
+		case len(M) > 1:
+			// 2.b. the next s
+			for s.Add(s, bigOne); !try(s); s.Add(s, bigOne) {
+			}
+		default:
+			// 2.c. one interval: r >= 2(b·s - 2B) / N, s in
+			// [(2B + rN) / b, (3B + rN) / a)
+			a, b := M[0].a, M[0].b
+			r := new(big.Int).Mul(b, s)
+			r.Sub(r, B2).Lsh(r, 1)
+			r = ceilDiv(r, n)
+			for found := false; !found; r.Add(r, bigOne) {
+				rn := new(big.Int).Mul(r, n)
+				lo := ceilDiv(new(big.Int).Add(B2, rn), b)
+				hi := ceilDiv(new(big.Int).Add(B3, rn), a)
+				for s.Set(lo); s.Cmp(hi) < 0; s.Add(s, bigOne) {
+					if try(s) {
+						found = true
+						break
+					}
+				}
+			}
+		}
+
+		// 3. narrow every interval with the s found
+		var next []interval
+		for _, in := range M {
+			rLo := new(big.Int).Mul(in.a, s)
+			rLo.Sub(rLo, B3m1)
+			rLo = ceilDiv(rLo, n)
+			rHi := new(big.Int).Mul(in.b, s)
+			rHi.Sub(rHi, B2).Div(rHi, n)
+			for r := rLo; r.Cmp(rHi) <= 0; r.Add(r, bigOne) {
+				rn := new(big.Int).Mul(r, n)
+				a := ceilDiv(new(big.Int).Add(B2, rn), s)
+				if a.Cmp(in.a) < 0 {
+					a.Set(in.a)
+				}
+				b := new(big.Int).Add(B3m1, rn)
+				b.Div(b, s)
+				if b.Cmp(in.b) > 0 {
+					b.Set(in.b)
+				}
+				if a.Cmp(b) <= 0 {
+					next = mergeInterval(next, interval{a, b})
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil, queries, errBleichenbacher
+		}
+		M = next
+
+		// 4. one candidate left: m = a·s0^-1 mod N
+		if len(M) == 1 && M[0].a.Cmp(M[0].b) == 0 {
+			m := new(big.Int).Set(M[0].a)
+			if s0.Cmp(bigOne) != 0 {
+				m.Mul(m, modMultiInverse(s0, n)).Mod(m, n)
+			}
+			return m.FillBytes(make([]byte, k)), queries, nil
+		}
+	}
+}
+
+// mergeInterval adds in to the disjoint intervals, merging the overlaps
+func mergeInterval(intervals []interval, in interval) []interval {
+	var merged []interval
+	for _, x := range intervals {
+		if x.b.Cmp(in.a) < 0 || in.b.Cmp(x.a) < 0 {
+			merged = append(merged, x)
+			continue
+		}
+		if x.a.Cmp(in.a) < 0 {
+			in.a = x.a
+		}
+		if x.b.Cmp(in.b) > 0 {
+			in.b = x.b
+		}
+	}
+	return append(merged, in)
+}
+
+// MangerAttack decrypts a ciphertext with an oracle telling whether the
+// first octet of m·f mod N is zero, m·f < B = 2^(8(k - 1)), as EME-OAEP
+// decoders do when they check Y apart from the rest (Manger, CRYPTO 2001).
+// It takes about log2(N) queries and returns the k-byte block m = c^D.
+func MangerAttack(pub *PublicKey, ciphertext []byte, oracle PaddingOracle) (em []byte, queries int, err error) {
+	k := pub.Size()
+	n, e := pub.N, big.NewInt(int64(pub.E))
+	B := new(big.Int).Lsh(bigOne, uint(8*(k-1)))
+	if len(ciphertext) != k || new(big.Int).Lsh(B, 1).Cmp(n) >= 0 {
+		return nil, 0, errManger
+	}
+
+	c0 := new(big.Int).SetBytes(ciphertext)
+	buf := make([]byte, k)
+	// below reports whether m·f mod N < B
+	below := func(f *big.Int) bool {
+		queries++
+		x := new(big.Int).Exp(f, e, n)
+		x.Mul(x, c0).Mod(x, n)
+		return oracle(x.FillBytes(buf))
+	}
+	if !below(bigOne) {
+		return nil, queries, errManger
+	}
+
+	// 1. double f1 until m·f1 >= B, then m·f1 / 2 is in [B/2, B)
+	f1 := big.NewInt(2)
+	for below(f1) {
+		f1.Lsh(f1, 1)
+	}
+	half := new(big.Int).Rsh(f1, 1)
+
+	// 2. step f2 by f1 / 2 from (N + B) / B · f1 / 2 until m·f2 is in
+	// [N, N + B)
+	nb := new(big.Int).Add(n, B)
+	f2 := new(big.Int).Quo(nb, B)
+	f2.Mul(f2, half)
+	for !below(f2) {
+		f2.Add(f2, half)
+	}
+
+	// 3. halve [mmin, mmax] with f3·m in [iN, iN + 2B) at every query
+	mmin, mmax := ceilDiv(n, f2), new(big.Int).Quo(nb, f2)
+	B2 := new(big.Int).Lsh(B, 1)
+	for mmin.Cmp(mmax) < 0 {
+		ftmp := new(big.Int).Quo(B2, new(big.Int).Sub(mmax, mmin))
+		i := new(big.Int).Mul(ftmp, mmin)
+		i.Quo(i, n)
+		in := new(big.Int).Mul(i, n)
+		f3 := ceilDiv(in, mmin)
+		inb := in.Add(in, B)
+		if below(f3) {
+			mmax.Quo(inb, f3)
+		} else {
+			mmin = ceilDiv(inb, f3)
+		}
+	}
+	return mmin.FillBytes(make([]byte, k)), queries, nil
+}
@@ -0,0 +1,129 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestWienerAttack(t *testing.T) {
+	p, _ := rand.Prime(rand.Reader, 512)
+	q, _ := rand.Prime(rand.Reader, 512)
+	n := new(big.Int).Mul(p, q)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+
+	// a 240-bit D is below N^(1/4) / 3
+	var d, e *big.Int
+	for e == nil {
+		d, _ = rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 240))
+		e = new(big.Int).ModInverse(d.SetBit(d, 0, 1), phi)
+	}
+	gotD, gotP, gotQ, err := WienerAttack(n, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotD.Cmp(d) != 0 || new(big.Int).Mul(gotP, gotQ).Cmp(n) != 0 {
+		t.Errorf("WienerAttack recovered D = %v, want %v", gotD, d)
+	}
+
+	priv, _ := GenerateKey(rand.Reader, 1024)
+	if _, _, _, err = WienerAttack(priv.N, big.NewInt(int64(priv.E))); err == nil {
+		t.Errorf("WienerAttack broke a key with E = %d", priv.E)
+	}
+}
+
+func TestHastadBroadcast(t *testing.T) {
+	var pubs []*PublicKey
+	for i := 0; i < 3; i++ {
+		priv, err := GenerateKeyWithOptions(1024, &KeyGenOptions{E: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, &priv.PublicKey)
+	}
+	// m must be below every modulus
+	m, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 1000))
+	var cs []*big.Int
+	for _, pub := range pubs {
+		cs = append(cs, encrypt(pub, m))
+	}
+
+	got, err := HastadBroadcast(pubs, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(m) != 0 {
+		t.Errorf("HastadBroadcast = %v, want %v", got, m)
+	}
+	if _, err = HastadBroadcast(pubs[:2], cs[:2]); err == nil {
+		t.Errorf("HastadBroadcast succeeded with 2 ciphertexts")
+	}
+	for _, e := range []int{-1, 0, 1} {
+		bad := []*PublicKey{{N: pubs[0].N, E: e}}
+		if _, err = HastadBroadcast(bad, cs[:1]); err == nil {
+			t.Errorf("HastadBroadcast succeeded with E = %d", e)
+		}
+	}
+
+	for _, x := range []int64{0, 1, 7, 8, 26, 27, 28, 1 << 40} {
+		for _, e := range []int{2, 3, 5} {
+			want := new(big.Int)
+			for new(big.Int).Exp(new(big.Int).Add(want, bigOne), big.NewInt(int64(e)), nil).Cmp(big.NewInt(x)) <= 0 {
+				want.Add(want, bigOne)
+			}
+			if got := iroot(big.NewInt(x), e); got.Cmp(want) != 0 {
+				t.Errorf("iroot(%d, %d) = %v, want %v", x, e, got, want)
+			}
+		}
+	}
+}
+
+func TestCommonModulusAttack(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader, 1024)
+	pub1, pub2 := &priv.PublicKey, &PublicKey{N: priv.N, E: 3}
+	m, _ := rand.Int(rand.Reader, priv.N)
+
+	got, err := CommonModulusAttack(pub1, pub2, encrypt(pub1, m), encrypt(pub2, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(m) != 0 {
+		t.Errorf("CommonModulusAttack = %v, want %v", got, m)
+	}
+	if _, err = CommonModulusAttack(pub1, &PublicKey{N: priv.N, E: 3 * 65537}, bigOne, bigOne); err == nil {
+		t.Errorf("CommonModulusAttack succeeded with E1 | E2")
+	}
+}
+
+func TestBleichenbacherAttack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the padding oracle attack takes thousands of decryptions")
+	}
+	priv, _ := GenerateKey(rand.Reader, 512)
+	msg := []byte("attack at dawn")
+	c, err := EncryptPKCS1v15(rand.Reader, &priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em, queries, err := BleichenbacherAttack(rand.Reader, &priv.PublicKey, c, LeakyPKCS1v15Oracle(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := emePKCS1v15Decode(em); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("BleichenbacherAttack = %q, want %q", got, msg)
+	}
+	t.Logf("%d oracle queries", queries)
+
+	// any ciphertext goes through the blinding step first
+	random, _ := rand.Int(rand.Reader, priv.N)
+	want, _ := decrypt(nil, priv, random)
+	em, queries, err = BleichenbacherAttack(rand.Reader, &priv.PublicKey, random.FillBytes(make([]byte, priv.Size())), LeakyPKCS1v15Oracle(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).SetBytes(em).Cmp(want) != 0 {
+		t.Errorf("BleichenbacherAttack of a random ciphertext = %x, want %x", em, want)
+	}
+	t.Logf("%d oracle queries with blinding", queries)
+}
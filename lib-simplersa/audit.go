@@ -0,0 +1,293 @@
+package lib_simplersa
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AuditSeverity grades an audit finding
+type AuditSeverity int
+
+const (
+	// AuditOK is a passed check
+	AuditOK AuditSeverity = iota
+	// AuditWarning is a weakness that does not break the key by itself
+	AuditWarning
+	// AuditCritical is a key that can be broken or is invalid
+	AuditCritical
+)
+
+func (s AuditSeverity) String() string {
+	switch s {
+	case AuditOK:
+		return "ok"
+	case AuditWarning:
+		return "warning"
+	case AuditCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("AuditSeverity(%d)", int(s))
+}
+
+// AuditFinding is the result of one check of a key
+type AuditFinding struct {
+	Check    string
+	Severity AuditSeverity
+	Message  string
+}
+
+// AuditReport is the structured result of AuditPublicKey or AuditPrivateKey
+type AuditReport struct {
+	Bits     int // bit length of N
+	NPrimes  int // number of primes, 0 for a public key
+	Findings []AuditFinding
+	// Factor is a nontrivial factor of N found by the public checks, nil
+	// if there is none
+	Factor *big.Int
+}
+
+// Severity returns the worst severity of the findings
+func (r *AuditReport) Severity() AuditSeverity {
+	worst := AuditOK
+	for _, f := range r.Findings {
+		if f.Severity > worst {
+			worst = f.Severity
+		}
+	}
+	return worst
+}
+
+func (r *AuditReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d-bit key: %s\n", r.Bits, r.Severity())
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", f.Severity, f.Check, f.Message)
+	}
+	return b.String()
+}
+
+func (r *AuditReport) add(check string, severity AuditSeverity, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, AuditFinding{check, severity, fmt.Sprintf(format, args...)})
+}
+
+const (
+	// auditFermatRounds is the number of steps of Fermat's method, it finds
+	// p and q with |p - q| up to about 2^(bits/4) * 2^7
+	auditFermatRounds = 1 << 14
+	// auditSmoothBits is the size below which the rough part of p ± 1 is in
+	// reach of the second stage of Pollard's p - 1 and Williams' p + 1
+	auditSmoothBits = 40
+)
+
+// rocaPrimes are the small primes of the ROCA fingerprint, keys of the
+// Infineon RSALib have N mod r in the subgroup generated by 65537 for all
+var rocaPrimes = []int{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73,
+	79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167,
+}
+
+// AuditPublicKey checks the modulus size, the public exponent, small prime
+// factors, close primes (Fermat), a smooth p - 1 (Pollard) and the ROCA
+// fingerprint of pub
+func AuditPublicKey(pub *PublicKey) *AuditReport {
+	r := new(AuditReport)
+	if pub.N == nil || pub.N.Sign() <= 0 {
+		r.add("modulus", AuditCritical, "missing modulus")
+		return r
+	}
+	r.Bits = pub.N.BitLen()
+	auditModulusSize(r)
+	auditPublicExponent(r, pub.E)
+	auditSmallFactors(r, pub.N)
+	if r.Factor == nil {
+		auditFermat(r, pub.N)
+	}
+	if r.Factor == nil {
+		auditPollard(r, pub.N)
+	}
+	auditROCA(r, pub.N)
+	return r
+}
+
+// AuditPrivateKey runs the public checks and, with the primes at hand, checks
+// the key consistency, the distance and balance of the primes, the
+// smoothness of every p ± 1 and the size of the private exponent
+func AuditPrivateKey(priv *PrivateKey) *AuditReport {
+	r := AuditPublicKey(&priv.PublicKey)
+	r.NPrimes = len(priv.Primes)
+	if err := priv.Validate(); err != nil {
+		r.add("consistency", AuditCritical, "%s", err)
+		return r
+	}
+	r.add("consistency", AuditOK, "the primes multiply to N and E·D ≡ 1 mod p - 1")
+	auditPrimeBalance(r, priv.Primes)
+	auditPrimeDistance(r, priv.Primes)
+	auditSmoothness(r, priv.Primes)
+	auditPrivateExponent(r, priv.N, priv.D)
+	return r
+}
+
+func auditModulusSize(r *AuditReport) {
+	switch {
+	case r.Bits < 1024:
+		r.add("modulus size", AuditCritical, "%d-bit N can be factored, use at least 2048 bits", r.Bits)
+	case r.Bits < 2048:
+		r.add("modulus size", AuditWarning, "%d-bit N is below the 2048 bits recommended by NIST SP 800-57", r.Bits)
+	default:
+		r.add("modulus size", AuditOK, "%d-bit N", r.Bits)
+	}
+}
+
+func auditPublicExponent(r *AuditReport, e int) {
+	switch {
+	case checkPublicExponent(e) != nil:
+		r.add("public exponent", AuditCritical, "E = %d: %s", e, checkPublicExponent(e))
+	case e < 65537:
+		r.add("public exponent", AuditWarning, "E = %d is small, unpadded or badly padded messages and lax signature verifiers are open to cube-root and broadcast attacks", e)
+	default:
+		r.add("public exponent", AuditOK, "E = %d", e)
+	}
+}
+
+func auditSmallFactors(r *AuditReport, n *big.Int) {
+	if n.Bit(0) == 0 {
+		r.Factor = big.NewInt(2)
+		r.add("small factors", AuditCritical, "N is even")
+		return
+	}
+	for j, res := range residues(n) {
+		if res == 0 && n.Cmp(big.NewInt(int64(sievePrimes[j]))) != 0 {
+			r.Factor = big.NewInt(int64(sievePrimes[j]))
+			r.add("small factors", AuditCritical, "N is divisible by %d", sievePrimes[j])
+			return
+		}
+	}
+	r.add("small factors", AuditOK, "no prime factor below %d", sieveLimit)
+}
+
+func auditFermat(r *AuditReport, n *big.Int) {
+	if p := FermatFactor(n, auditFermatRounds); p != nil {
+		r.Factor = p
+		r.add("close primes", AuditCritical, "Fermat's method factors N in at most %d steps, N = %v · ...", auditFermatRounds, p)
+		return
+	}
+	r.add("close primes", AuditOK, "Fermat's method fails in %d steps", auditFermatRounds)
+}
+
+func auditPollard(r *AuditReport, n *big.Int) {
+	if p := PollardPM1(n, sieveLimit); p != nil {
+		r.Factor = p
+		r.add("smooth p - 1", AuditCritical, "Pollard's p - 1 with B = %d factors N, N = %v · ...", sieveLimit, p)
+		return
+	}
+	r.add("smooth p - 1", AuditOK, "Pollard's p - 1 with B = %d fails", sieveLimit)
+}
+
+func auditROCA(r *AuditReport, n *big.Int) {
+	m := new(big.Int)
+	for _, p := range rocaPrimes {
+		if !rocaSubgroup(p, int(m.Mod(n, big.NewInt(int64(p))).Int64())) {
+			r.add("ROCA", AuditOK, "no ROCA fingerprint")
+			return
+		}
+	}
+	r.add("ROCA", AuditCritical, "N has the ROCA fingerprint (CVE-2017-15361) of the Infineon RSALib primes")
+}
+
+// rocaSubgroup reports whether x is a power of 65537 mod the prime p
+func rocaSubgroup(p, x int) bool {
+	g := 65537 % p
+	for y := g; ; y = y * g % p {
+		if y == x {
+			return true
+		}
+		if y == 1 {
+			return false
+		}
+	}
+}
+
+func auditPrimeBalance(r *AuditReport, primes []*big.Int) {
+	even := r.Bits / len(primes)
+	smallest := primes[0].BitLen()
+	for _, p := range primes {
+		if p.BitLen() < smallest {
+			smallest = p.BitLen()
+		}
+	}
+	switch {
+	case smallest < 256:
+		r.add("prime balance", AuditCritical, "a %d-bit prime is within reach of ECM", smallest)
+	case smallest*5 < even*4:
+		r.add("prime balance", AuditWarning, "the smallest prime has %d bits against %d for balanced primes, ECM finds it sooner", smallest, even)
+	default:
+		r.add("prime balance", AuditOK, "%d primes of at least %d bits", len(primes), smallest)
+	}
+}
+
+func auditPrimeDistance(r *AuditReport, primes []*big.Int) {
+	// FIPS 186-5 A.1.3 asks |p - q| > 2^(nlen/2 - 100)
+	bound := r.Bits/len(primes) - 100
+	d := new(big.Int)
+	for i := range primes {
+		for j := i + 1; j < len(primes); j++ {
+			if d.Sub(primes[i], primes[j]).Abs(d).BitLen() <= bound {
+				r.add("prime distance", AuditCritical, "primes %d and %d differ by a %d-bit number only", i, j, d.BitLen())
+				return
+			}
+		}
+	}
+	r.add("prime distance", AuditOK, "the primes differ by more than 2^%d", bound)
+}
+
+func auditSmoothness(r *AuditReport, primes []*big.Int) {
+	worst, msg := AuditOK, fmt.Sprintf("every p ± 1 has a rough part of more than %d bits", auditSmoothBits)
+	x := new(big.Int)
+	for i, p := range primes {
+		for _, s := range []struct {
+			name string
+			x    *big.Int
+		}{{"p - 1", x.Sub(p, bigOne)}, {"p + 1", new(big.Int).Add(p, bigOne)}} {
+			rough := roughPart(s.x).BitLen()
+			switch {
+			case rough <= 1:
+				r.add("smoothness", AuditCritical, "%s of prime %d is %d-smooth", s.name, i, sieveLimit)
+				return
+			case rough <= auditSmoothBits && worst == AuditOK:
+				worst, msg = AuditWarning, fmt.Sprintf("%s of prime %d has a rough part of %d bits only", s.name, i, rough)
+			}
+		}
+	}
+	r.add("smoothness", worst, "%s", msg)
+}
+
+// roughPart returns x without its prime factors below sieveLimit
+func roughPart(x *big.Int) *big.Int {
+	x = new(big.Int).Rsh(x, x.TrailingZeroBits())
+	q, m := new(big.Int), new(big.Int)
+	for j, res := range residues(x) {
+		if res != 0 {
+			continue
+		}
+		p := big.NewInt(int64(sievePrimes[j]))
+		for q.QuoRem(x, p, m); m.Sign() == 0 && x.Sign() != 0; q.QuoRem(x, p, m) {
+			x.Set(q)
+		}
+	}
+	return x
+}
+
+func auditPrivateExponent(r *AuditReport, n, d *big.Int) {
+	// Wiener: d < N^(1/4) / 3, Boneh-Durfee: d < N^0.292
+	d4 := new(big.Int).Exp(d, big.NewInt(4), nil)
+	d4.Mul(d4, big.NewInt(81))
+	switch {
+	case d4.Cmp(n) < 0:
+		r.add("private exponent", AuditCritical, "%d-bit D is below Wiener's bound N^(1/4) / 3", d.BitLen())
+	case float64(d.BitLen()) < 0.292*float64(r.Bits):
+		r.add("private exponent", AuditCritical, "%d-bit D is below the Boneh-Durfee bound N^0.292", d.BitLen())
+	default:
+		r.add("private exponent", AuditOK, "%d-bit D", d.BitLen())
+	}
+}
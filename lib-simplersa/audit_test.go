@@ -0,0 +1,146 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// auditKey builds a 2-prime key of p and q with E = 65537, or the given d
+func auditKey(t *testing.T, p, q *big.Int, d int64) *PrivateKey {
+	priv := &PrivateKey{PublicKey: PublicKey{N: new(big.Int).Mul(p, q), E: 65537}, Primes: []*big.Int{p, q}}
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+	if d == 0 {
+		priv.D = new(big.Int).ModInverse(big.NewInt(65537), phi)
+	} else {
+		priv.D = big.NewInt(d)
+		priv.E = int(new(big.Int).ModInverse(priv.D, phi).Int64())
+	}
+	if priv.D == nil || priv.E == 0 {
+		t.Fatalf("no private exponent for p = %v, q = %v", p, q)
+	}
+	return priv
+}
+
+// auditSeverity returns the severity of the check in r
+func auditSeverity(t *testing.T, r *AuditReport, check string) AuditSeverity {
+	for _, f := range r.Findings {
+		if f.Check == check {
+			return f.Severity
+		}
+	}
+	t.Fatalf("no %q finding in\n%s", check, r)
+	return 0
+}
+
+func TestAuditGoodKey(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := AuditPrivateKey(priv); r.Severity() != AuditOK || r.Factor != nil || r.NPrimes != 2 {
+		t.Errorf("a fresh 2048-bit key fails the audit:\n%s", r)
+	}
+	if r := AuditPublicKey(&test2048Key.PublicKey); r.Severity() != AuditWarning || auditSeverity(t, r, "public exponent") != AuditWarning {
+		t.Errorf("E = 3 is not reported:\n%s", r)
+	}
+}
+
+func TestAuditWeakKeys(t *testing.T) {
+	p, _ := rand.Prime(rand.Reader, 512)
+
+	// q close to p
+	q := new(big.Int).Lsh(bigOne, 100)
+	q.Add(q, p)
+	for !q.ProbablyPrime(20) {
+		q.Add(q, bigTwo)
+	}
+	r := AuditPrivateKey(auditKey(t, p, q, 0))
+	if auditSeverity(t, r, "close primes") != AuditCritical || auditSeverity(t, r, "prime distance") != AuditCritical {
+		t.Errorf("close primes are not reported:\n%s", r)
+	}
+	if r.Factor == nil || (r.Factor.Cmp(p) != 0 && r.Factor.Cmp(q) != 0) {
+		t.Errorf("Fermat's method did not recover p, got %v", r.Factor)
+	}
+
+	// q - 1 = 2 * a product of distinct small primes
+	for {
+		q = big.NewInt(2)
+		used := make(map[int64]bool)
+		for q.BitLen() < 500 {
+			f, _ := rand.Int(rand.Reader, big.NewInt(int64(len(sievePrimes))))
+			if !used[f.Int64()] {
+				used[f.Int64()] = true
+				q.Mul(q, big.NewInt(int64(sievePrimes[f.Int64()])))
+			}
+		}
+		if q.Add(q, bigOne).ProbablyPrime(20) && new(big.Int).GCD(nil, nil, new(big.Int).Sub(q, bigOne), big.NewInt(65537)).Cmp(bigOne) == 0 {
+			break
+		}
+	}
+	r = AuditPrivateKey(auditKey(t, p, q, 0))
+	if auditSeverity(t, r, "smooth p - 1") != AuditCritical || auditSeverity(t, r, "smoothness") != AuditCritical {
+		t.Errorf("smooth q - 1 is not reported:\n%s", r)
+	}
+	if r.Factor == nil || r.Factor.Cmp(q) != 0 {
+		t.Errorf("Pollard's p - 1 did not recover q, got %v", r.Factor)
+	}
+
+	// a small factor, unbalanced primes
+	r = AuditPrivateKey(auditKey(t, p, big.NewInt(32749), 0))
+	if auditSeverity(t, r, "small factors") != AuditCritical || auditSeverity(t, r, "prime balance") != AuditCritical {
+		t.Errorf("a small factor is not reported:\n%s", r)
+	}
+
+	// Wiener: a 30-bit N keeps E = D^-1 in an int
+	r = AuditPrivateKey(auditKey(t, big.NewInt(32719), big.NewInt(32749), 37))
+	if auditSeverity(t, r, "private exponent") != AuditCritical {
+		t.Errorf("D = 37 is not reported:\n%s", r)
+	}
+
+	// invalid keys
+	priv := auditKey(t, p, q, 0)
+	priv.D = new(big.Int).Add(priv.D, bigOne)
+	if r = AuditPrivateKey(priv); auditSeverity(t, r, "consistency") != AuditCritical {
+		t.Errorf("a wrong D is not reported:\n%s", r)
+	}
+	if r = AuditPublicKey(&PublicKey{N: priv.N, E: 4}); auditSeverity(t, r, "public exponent") != AuditCritical {
+		t.Errorf("E = 4 is not reported:\n%s", r)
+	}
+	if r = AuditPublicKey(&PublicKey{}); r.Severity() != AuditCritical {
+		t.Errorf("a missing N is not reported:\n%s", r)
+	}
+}
+
+func TestAuditROCA(t *testing.T) {
+	// ROCA primes are k * M + (65537^a mod M) for the primorial-like M
+	m := big.NewInt(1)
+	for _, r := range rocaPrimes {
+		m.Mul(m, big.NewInt(int64(r)))
+	}
+	rocaPrime := func() *big.Int {
+		for {
+			k, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 512-uint(m.BitLen())))
+			a, _ := rand.Int(rand.Reader, m)
+			p := new(big.Int).Exp(big.NewInt(65537), a, m)
+			p.Add(p, k.Mul(k, m))
+			if p.BitLen() == 512 && p.ProbablyPrime(20) {
+				return p
+			}
+		}
+	}
+	r := AuditPrivateKey(auditKey(t, rocaPrime(), rocaPrime(), 0))
+	if auditSeverity(t, r, "ROCA") != AuditCritical {
+		t.Errorf("the ROCA fingerprint is not reported:\n%s", r)
+	}
+
+	for _, r := range rocaPrimes {
+		if !rocaSubgroup(r, 1) || !rocaSubgroup(r, 65537%r) {
+			t.Errorf("1 or 65537 are not in the subgroup mod %d", r)
+		}
+	}
+	if rocaSubgroup(11, 2) {
+		// 65537 ≡ 10 mod 11 generates {1, 10}
+		t.Errorf("2 is in the subgroup of 65537 mod 11")
+	}
+}
@@ -0,0 +1,254 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Key backup with Shamir's secret sharing over GF(2^8): the serialized key
+// is split byte by byte with random polynomials of degree t - 1, share x
+// holds f(x) of every byte. Any t shares give the key back, fewer tell
+// nothing of it. The arithmetic runs in constant time, no table lookup
+// depends on the key.
+//
+// A share is "SRSA-" and the unpadded base32 of
+//
+//	version (1) || backup id (4) || t (1) || x (1) || f(x) || checksum (4)
+//
+// uppercase letters, digits and '-' only, the alphanumeric mode of QR codes.
+// The checksum is the start of SHA-256 of the preceding bytes, it catches
+// typing errors. The random backup id keeps shares of different backups
+// apart.
+
+var (
+	errBackupParams = errors.New("simple_rsa: a backup needs 2 <= threshold <= shares <= 255")
+	errBackupShare  = errors.New("simple_rsa: malformed backup share")
+	errBackupMix    = errors.New("simple_rsa: backup shares of different backups or repeated shares")
+	errBackupShares = errors.New("simple_rsa: not enough backup shares")
+	errBackupKey    = errors.New("simple_rsa: malformed backed up key")
+)
+
+const (
+	backupPrefix  = "SRSA-"
+	backupVersion = 1
+	// backupHeader is the length of version, id, t and x
+	backupHeader   = 7
+	backupChecksum = 4
+)
+
+var backupEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// gfMul returns x·y in GF(2^8) mod x^8 + x^4 + x^3 + x + 1, in constant time
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 0; i < 8; i++ {
+		z ^= x & -(y & 1)
+		x = x<<1 ^ 0x1b&-(x>>7)
+		y >>= 1
+	}
+	return z
+}
+
+// gfInv returns x^-1 = x^254 in GF(2^8), 0 for 0
+func gfInv(x byte) byte {
+	z := x
+	for i := 0; i < 6; i++ {
+		z = gfMul(z, z)
+		z = gfMul(z, x)
+	}
+	return gfMul(z, z)
+}
+
+// shamirSplit returns the shares f(1), ..., f(n) of every byte of secret
+func shamirSplit(random io.Reader, secret []byte, n, t int) ([][]byte, error) {
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+	f := make([]byte, t)
+	for j, s := range secret {
+		if _, err := io.ReadFull(random, f[1:]); err != nil {
+			return nil, err
+		}
+		f[0] = s
+		for i := range shares {
+			// Horner's rule at x = i + 1
+			x, y := byte(i+1), byte(0)
+			for k := t - 1; k >= 0; k-- {
+				y = gfMul(y, x) ^ f[k]
+			}
+			shares[i][j] = y
+		}
+	}
+	for i := range f {
+		f[i] = 0
+	}
+	return shares, nil
+}
+
+// shamirCombine interpolates f(0) from the shares ys at the distinct
+// nonzero points xs
+func shamirCombine(xs []byte, ys [][]byte) []byte {
+	// Lagrange coefficients at 0: ∏_(m≠j) x_m / (x_m - x_j), - is ^
+	lagrange := make([]byte, len(xs))
+	for j := range xs {
+		num, den := byte(1), byte(1)
+		for m := range xs {
+			if m != j {
+				num = gfMul(num, xs[m])
+				den = gfMul(den, xs[m]^xs[j])
+			}
+		}
+		lagrange[j] = gfMul(num, gfInv(den))
+	}
+	secret := make([]byte, len(ys[0]))
+	for j, y := range ys {
+		for i := range secret {
+			secret[i] ^= gfMul(lagrange[j], y[i])
+		}
+	}
+	return secret
+}
+
+// marshalBackupKey serializes E, N, D and the primes of priv:
+// E (4) || count (2) || count times length (2) || big-endian value
+func marshalBackupKey(priv *PrivateKey) []byte {
+	values := append([]*big.Int{priv.N, priv.D}, priv.Primes...)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(priv.E))
+	binary.Write(&buf, binary.BigEndian, uint16(len(values)))
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, uint16(len(v.Bytes())))
+		buf.Write(v.Bytes())
+	}
+	return buf.Bytes()
+}
+
+// unmarshalBackupKey parses the output of marshalBackupKey
+func unmarshalBackupKey(data []byte) (*PrivateKey, error) {
+	if len(data) < 6 {
+		return nil, errBackupKey
+	}
+	e, count := binary.BigEndian.Uint32(data), int(binary.BigEndian.Uint16(data[4:]))
+	data = data[6:]
+	if count < 4 || e > 1<<31-1 {
+		return nil, errBackupKey
+	}
+	values := make([]*big.Int, count)
+	for i := range values {
+		if len(data) < 2 {
+			return nil, errBackupKey
+		}
+		l := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+l {
+			return nil, errBackupKey
+		}
+		values[i] = new(big.Int).SetBytes(data[2 : 2+l])
+		data = data[2+l:]
+	}
+	if len(data) != 0 {
+		return nil, errBackupKey
+	}
+	return &PrivateKey{PublicKey: PublicKey{N: values[0], E: int(e)}, D: values[1], Primes: values[2:]}, nil
+}
+
+// SplitPrivateKey serializes priv and splits it into n printable shares,
+// any threshold of which restore it with CombinePrivateKey
+func SplitPrivateKey(random io.Reader, priv *PrivateKey, n, threshold int) ([]string, error) {
+	if threshold < 2 || n < threshold || n > 255 {
+		return nil, errBackupParams
+	}
+	if err := priv.Validate(); err != nil {
+		return nil, err
+	}
+	secret := marshalBackupKey(priv)
+	defer func() {
+		for i := range secret {
+			secret[i] = 0
+		}
+	}()
+	id := make([]byte, 4)
+	if _, err := io.ReadFull(random, id); err != nil {
+		return nil, err
+	}
+	ys, err := shamirSplit(random, secret, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]string, n)
+	for i, y := range ys {
+		share := append([]byte{backupVersion}, id...)
+		share = append(share, byte(threshold), byte(i+1))
+		share = append(share, y...)
+		sum := sha256.Sum256(share)
+		share = append(share, sum[:backupChecksum]...)
+		shares[i] = backupPrefix + backupEncoding.EncodeToString(share)
+	}
+	return shares, nil
+}
+
+// parseBackupShare decodes a share, ignoring white space and case
+func parseBackupShare(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if !strings.HasPrefix(s, backupPrefix) {
+		return nil, errBackupShare
+	}
+	share, err := backupEncoding.DecodeString(s[len(backupPrefix):])
+	if err != nil || len(share) <= backupHeader+backupChecksum {
+		return nil, errBackupShare
+	}
+	body := share[:len(share)-backupChecksum]
+	sum := sha256.Sum256(body)
+	if !bytes.Equal(sum[:backupChecksum], share[len(body):]) || body[0] != backupVersion || body[5] < 2 || body[6] == 0 {
+		return nil, errBackupShare
+	}
+	return body, nil
+}
+
+// CombinePrivateKey restores a key from at least threshold shares of
+// SplitPrivateKey, the key is validated and precomputed
+func CombinePrivateKey(shares []string) (*PrivateKey, error) {
+	var (
+		header []byte
+		xs     []byte
+		ys     [][]byte
+	)
+	for _, s := range shares {
+		share, err := parseBackupShare(s)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			header = share[:backupHeader-1]
+		}
+		if !bytes.Equal(share[:backupHeader-1], header) || len(ys) > 0 && len(share)-backupHeader != len(ys[0]) || bytes.IndexByte(xs, share[6]) >= 0 {
+			return nil, errBackupMix
+		}
+		xs = append(xs, share[6])
+		ys = append(ys, share[backupHeader:])
+	}
+	if header == nil || len(xs) < int(header[5]) {
+		return nil, errBackupShares
+	}
+	t := int(header[5])
+	secret := shamirCombine(xs[:t], ys[:t])
+	priv, err := unmarshalBackupKey(secret)
+	for i := range secret {
+		secret[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := priv.Validate(); err != nil {
+		return nil, err
+	}
+	priv.Precompute()
+	return priv, nil
+}
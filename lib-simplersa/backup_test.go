@@ -0,0 +1,80 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGF256(t *testing.T) {
+	// the AES field: {57}·{83} = {c1}, FIPS 197 section 4.2
+	if got := gfMul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("gfMul(0x57, 0x83) = %#x, want 0xc1", got)
+	}
+	for x := 1; x < 256; x++ {
+		if got := gfMul(byte(x), gfInv(byte(x))); got != 1 {
+			t.Fatalf("%#x · %#x^-1 = %#x", x, x, got)
+		}
+	}
+}
+
+func TestSplitPrivateKey(t *testing.T) {
+	priv, err := GenerateMultiPrimeKey(rand.Reader, 3, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := SplitPrivateKey(rand.Reader, priv, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printable := regexp.MustCompile(`^SRSA-[A-Z2-7]+$`)
+	for _, share := range shares {
+		if !printable.MatchString(share) {
+			t.Fatalf("share %q is not QR alphanumeric", share)
+		}
+	}
+
+	for _, pick := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 2, 3, 4}} {
+		var some []string
+		for _, i := range pick {
+			some = append(some, shares[i])
+		}
+		got, err := CombinePrivateKey(some)
+		if err != nil {
+			t.Fatalf("shares %v: %s", pick, err)
+		}
+		if !got.Equal(priv) {
+			t.Errorf("shares %v restore another key", pick)
+		}
+	}
+	// white space and case do not matter, as when typed from paper
+	typed := strings.ToLower(shares[3][:20]) + "\n " + shares[3][20:]
+	if got, err := CombinePrivateKey([]string{shares[0], typed, shares[1]}); err != nil || !got.Equal(priv) {
+		t.Errorf("typed share: %v", err)
+	}
+
+	if _, err := CombinePrivateKey(shares[:2]); err != errBackupShares {
+		t.Errorf("2 of 3 shares: got %v, want %v", err, errBackupShares)
+	}
+	if _, err := CombinePrivateKey([]string{shares[0], shares[0], shares[1]}); err != errBackupMix {
+		t.Errorf("a repeated share: got %v, want %v", err, errBackupMix)
+	}
+	other, _ := SplitPrivateKey(rand.Reader, priv, 5, 3)
+	if _, err := CombinePrivateKey([]string{shares[0], shares[1], other[2]}); err != errBackupMix {
+		t.Errorf("shares of two backups: got %v, want %v", err, errBackupMix)
+	}
+	// a typing error fails the checksum
+	b := []byte(shares[2])
+	if b[30] == 'A' {
+		b[30] = 'B'
+	} else {
+		b[30] = 'A'
+	}
+	if _, err := CombinePrivateKey([]string{shares[0], shares[1], string(b)}); err != errBackupShare {
+		t.Errorf("a mistyped share: got %v, want %v", err, errBackupShare)
+	}
+	if _, err := SplitPrivateKey(rand.Reader, priv, 3, 1); err != errBackupParams {
+		t.Errorf("threshold 1: got %v, want %v", err, errBackupParams)
+	}
+}
@@ -0,0 +1,566 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+var errFiatExponents = errors.New("simple_rsa: Fiat batch exponents must be pairwise coprime and coprime to every p-1")
+
+// BatchOptions configures the batch operations
+type BatchOptions struct {
+	// Workers is the size of the goroutine pool, defaults to runtime.NumCPU().
+	Workers int
+
+	// Screen makes VerifyBatchPKCS1v15 test the whole batch at once with
+	// (s_1^r_1 * ... * s_n^r_n)^e == EM_1^r_1 * ... * EM_n^r_n mod N for
+	// random 64-bit r_i and verify the signatures one by one only if that
+	// fails. Invalid signatures whose errors cancel out in the plain product
+	// pass it only with probability 2^-64. The exception is a signature
+	// replaced by N - s: -1 is known without factoring N and has order 2,
+	// so such a batch passes with probability 1/2. The random exponents
+	// cost about 64 multiplications per signature, more than a verification
+	// with any E below 2^31, so screening is slower than verifying one by one.
+	Screen bool
+}
+
+func (opts *BatchOptions) workers() int {
+	if opts == nil || opts.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return opts.Workers
+}
+
+// parallelFor calls f(0), ..., f(n-1) on a pool of workers goroutines
+func parallelFor(n, workers int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// decryptBatch returns RSADP((n, d), cs[i]) for every input. With the CRT
+// every exponentiation modulo every prime is a job of its own, so even a
+// small batch keeps the whole pool busy. The random values are all drawn
+// before the pool starts, random is never shared between goroutines.
+func decryptBatch(random io.Reader, priv *PrivateKey, cs []*big.Int, opts *BatchOptions) ([]*big.Int, []error) {
+	ms, errs := make([]*big.Int, len(cs)), make([]error, len(cs))
+	if priv.Precomputed.Dq == nil || !priv.Precomputed.hasMont(len(priv.Primes)) {
+		// no CRT, one job per input with a reader of its own
+		readers := make([]io.Reader, len(cs))
+		for i := range cs {
+			if readers[i], errs[i] = batchReader(random); errs[i] != nil {
+				// the later inputs are not decrypted either
+				fillErrors(errs[i:], errs[i])
+				return ms, errs
+			}
+		}
+		parallelFor(len(cs), opts.workers(), func(i int) {
+			ms[i], errs[i] = decrypt(readers[i], priv, cs[i])
+		})
+		return ms, errs
+	}
+
+	// 1. blind the inputs and the exponents
+	type item struct {
+		c, rInv *big.Int
+		exps    []*big.Int
+		ybits   []int
+		parts   [][]big.Word
+	}
+	items := make([]item, len(cs))
+	for i, c := range cs {
+		if c.Cmp(priv.N) >= 0 {
+			errs[i] = ErrDecryption
+			continue
+		}
+		it, blinded, err := &items[i], c, error(nil)
+		if random != nil {
+			blinded, it.rInv, err = randomMulCiphertext(random, priv, c)
+		}
+		if err == nil {
+			it.exps, it.ybits, err = priv.crtExponents(random)
+		}
+		if err != nil {
+			// the reader failed, the inputs blinded so far are still decrypted
+			fillErrors(errs[i:], err)
+			break
+		}
+		it.c, it.parts = blinded, make([][]big.Word, len(priv.Primes))
+	}
+
+	// 2. one job per input and prime
+	nprimes := len(priv.Primes)
+	parallelFor(len(cs)*nprimes, opts.workers(), func(job int) {
+		it, i := &items[job/nprimes], job%nprimes
+		if it.c != nil {
+			it.parts[i] = expCRT(priv, it.c, i, it.exps[i], it.ybits[i])
+		}
+	})
+
+	// 3. recombine, check and unblind every input
+	parallelFor(len(cs), opts.workers(), func(i int) {
+		it := &items[i]
+		if it.c == nil {
+			return
+		}
+		m := combineCRT(priv, it.parts)
+		if encrypt(&priv.PublicKey, m).Cmp(it.c) != 0 {
+			errs[i] = errInternal
+			return
+		}
+		if it.rInv != nil {
+			m = mulModN(priv, m, it.rInv)
+		}
+		ms[i] = m
+	})
+	return ms, errs
+}
+
+// batchReader returns a reader that can be used by a goroutine of its own:
+// nil stays nil, crypto/rand.Reader is safe for concurrent use, any other
+// reader is replaced with a snapshot of enough of its output
+func batchReader(random io.Reader) (io.Reader, error) {
+	if random == nil || random == rand.Reader {
+		return random, nil
+	}
+	// a blinding value, its inverse retries and the blinded exponent
+	buf := make([]byte, 4096)
+	if _, err := io.ReadFull(random, buf); err != nil {
+		return nil, err
+	}
+	return &byteReader{buf: buf}, nil
+}
+
+// byteReader reads a fixed buffer, then fails with io.ErrUnexpectedEOF
+type byteReader struct {
+	buf []byte
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// SignBatch signs every digest with PKCS #1 v1.5 like SignPKCS1v15, spreading
+// the exponentiations modulo every prime of every digest over a pool of
+// goroutines. errs[i] is the error of the i-th signature.
+func SignBatch(random io.Reader, priv *PrivateKey, hash crypto.Hash, digests [][]byte, opts *BatchOptions) (sigs [][]byte, errs []error) {
+	k := priv.Size()
+	sigs, errs = make([][]byte, len(digests)), make([]error, len(digests))
+	if err := checkPub(&priv.PublicKey); err != nil {
+		return sigs, fillErrors(errs, err)
+	}
+
+	ms, index := make([]*big.Int, 0, len(digests)), make([]int, 0, len(digests))
+	for i, digest := range digests {
+		em, err := emsaPKCS1v15Encode(hash, digest, k)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		ms, index = append(ms, new(big.Int).SetBytes(em)), append(index, i)
+	}
+
+	ss, sErrs := decryptBatch(random, priv, ms, opts)
+	for j, i := range index {
+		if errs[i] = sErrs[j]; errs[i] == nil {
+			sigs[i] = ss[j].FillBytes(make([]byte, k))
+		}
+	}
+	return sigs, errs
+}
+
+// DecryptBatch decrypts every PKCS #1 v1.5 ciphertext like
+// DecryptPKCS1v15ImplicitRejection, spreading the exponentiations modulo
+// every prime of every ciphertext over a pool of goroutines. A badly padded
+// ciphertext decrypts to its synthetic message, so errs[i] is only set for
+// a ciphertext of the wrong length or a failed decryption.
+func DecryptBatch(random io.Reader, priv *PrivateKey, ciphertexts [][]byte, opts *BatchOptions) (msgs [][]byte, errs []error) {
+	k := priv.Size()
+	msgs, errs = make([][]byte, len(ciphertexts)), make([]error, len(ciphertexts))
+	if err := checkPub(&priv.PublicKey); err != nil {
+		return msgs, fillErrors(errs, err)
+	}
+
+	cs, index := make([]*big.Int, 0, len(ciphertexts)), make([]int, 0, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		if len(ciphertext) != k || k < 11 {
+			errs[i] = ErrDecryption
+			continue
+		}
+		cs, index = append(cs, new(big.Int).SetBytes(ciphertext)), append(index, i)
+	}
+
+	ms, mErrs := decryptBatch(random, priv, cs, opts)
+	for j, i := range index {
+		if errs[i] = mErrs[j]; errs[i] == nil {
+			msgs[i] = implicitRejectionDecode(priv, ciphertexts[i], ms[j].FillBytes(make([]byte, k)))
+		}
+	}
+	return msgs, errs
+}
+
+// fillErrors sets every entry of errs to err
+func fillErrors(errs []error, err error) []error {
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// FiatBatchKey is a private key shared by several public keys (N, e_i) with
+// small, pairwise coprime exponents. Fiat's batch RSA computes the e_i-th
+// roots of a batch of inputs, the i-th one under Exponents[i], with a single
+// full-size exponentiation and a tree of exponentiations by products of the
+// small exponents.
+type FiatBatchKey struct {
+	*PrivateKey
+
+	// Exponents are the public exponents, the i-th input of a batch is
+	// processed with Exponents[i]
+	Exponents []int
+}
+
+// NewFiatBatchKey returns the batch key of priv for the given exponents,
+// which must be odd, pairwise coprime and coprime to every p-1
+func NewFiatBatchKey(priv *PrivateKey, exponents []int) (*FiatBatchKey, error) {
+	if len(priv.Primes) < 2 || len(exponents) == 0 {
+		return nil, errFiatExponents
+	}
+	for i, e := range exponents {
+		if err := checkPublicExponent(e); err != nil {
+			return nil, err
+		}
+		bigE := big.NewInt(int64(e))
+		for _, other := range exponents[:i] {
+			if exGcd(bigE, big.NewInt(int64(other)), nil, nil).Cmp(bigOne) != 0 {
+				return nil, errFiatExponents
+			}
+		}
+		for _, prime := range priv.Primes {
+			pminus1 := new(big.Int).Sub(prime, bigOne)
+			if exGcd(bigE, pminus1, nil, nil).Cmp(bigOne) != 0 {
+				return nil, errFiatExponents
+			}
+		}
+	}
+	priv.Precompute()
+	if !priv.Precomputed.hasMont(len(priv.Primes)) {
+		return nil, errFiatExponents
+	}
+	return &FiatBatchKey{PrivateKey: priv, Exponents: append([]int(nil), exponents...)}, nil
+}
+
+// PublicKeyAt returns the public key (N, Exponents[i])
+func (key *FiatBatchKey) PublicKeyAt(i int) *PublicKey {
+	return &PublicKey{N: key.N, E: key.Exponents[i]}
+}
+
+// fiatNode is a node of the batch tree over the inputs lo..hi-1, e is the
+// product of their exponents and v = prod c_i^(e / e_i)
+type fiatNode struct {
+	lo, hi      int
+	e, v        *big.Int
+	left, right *fiatNode
+}
+
+// fiatTree builds the batch tree bottom up, v = vL^eR * vR^eL
+func (key *FiatBatchKey) fiatTree(cs []*big.Int, lo, hi int) *fiatNode {
+	if hi-lo == 1 {
+		return &fiatNode{lo: lo, hi: hi, e: big.NewInt(int64(key.Exponents[lo])), v: cs[lo]}
+	}
+	mid := (lo + hi) / 2
+	node := &fiatNode{lo: lo, hi: hi, left: key.fiatTree(cs, lo, mid), right: key.fiatTree(cs, mid, hi)}
+	node.e = new(big.Int).Mul(node.left.e, node.right.e)
+	node.v = new(big.Int).Exp(node.left.v, node.right.e, key.N)
+	node.v.Mul(node.v, new(big.Int).Exp(node.right.v, node.left.e, key.N)).Mod(node.v, key.N)
+	return node
+}
+
+// fiatSplit splits r = prod c_i^(1 / e_i) of the node into the roots of its
+// inputs. With X ≡ 0 mod eL, X ≡ 1 mod eR the root of the right half is
+// r^X / (vL^(X / eL) * vR^((X - 1) / eR)), the left half is symmetric.
+// Only the public v values are ever inverted.
+func (key *FiatBatchKey) fiatSplit(node *fiatNode, r *big.Int, roots []*big.Int) error {
+	if node.left == nil {
+		roots[node.lo] = r
+		return nil
+	}
+	eL, eR := node.left.e, node.right.e
+	rR, err := key.fiatHalf(r, node.left, node.right, eL, eR)
+	if err != nil {
+		return err
+	}
+	rL, err := key.fiatHalf(r, node.right, node.left, eR, eL)
+	if err != nil {
+		return err
+	}
+	if err = key.fiatSplit(node.left, rL, roots); err != nil {
+		return err
+	}
+	return key.fiatSplit(node.right, rR, roots)
+}
+
+// fiatHalf returns the root of the half `keep` of r, the other half is `drop`
+func (key *FiatBatchKey) fiatHalf(r *big.Int, drop, keep *fiatNode, eDrop, eKeep *big.Int) (*big.Int, error) {
+	// X = eDrop * (eDrop^-1 mod eKeep)
+	x := modMultiInverse(eDrop, eKeep)
+	if x == nil {
+		return nil, errFiatExponents
+	}
+	x.Mul(x, eDrop)
+
+	den := new(big.Int).Exp(drop.v, new(big.Int).Quo(x, eDrop), key.N)
+	xMinus1 := new(big.Int).Sub(x, bigOne)
+	den.Mul(den, new(big.Int).Exp(keep.v, xMinus1.Quo(xMinus1, eKeep), key.N)).Mod(den, key.N)
+	// the inverses of the batch are on the hot path, big.Int.ModInverse is
+	// much faster than modMultiInverse on N-sized values
+	if den = den.ModInverse(den, key.N); den == nil {
+		return nil, ErrDecryption
+	}
+	half := new(big.Int).Exp(r, x, key.N)
+	return half.Mul(half, den).Mod(half, key.N), nil
+}
+
+// fiatRoots returns cs[i]^(1 / Exponents[i]) mod N. Inputs that are not
+// invertible mod N get ErrDecryption and are left out of the batch.
+func (key *FiatBatchKey) fiatRoots(random io.Reader, cs []*big.Int) ([]*big.Int, []error) {
+	roots, errs := make([]*big.Int, len(cs)), make([]error, len(cs))
+	if len(cs) > len(key.Exponents) {
+		return roots, fillErrors(errs, errFiatExponents)
+	}
+
+	// 1. blind every input with r_i^e_i and keep the invertible ones
+	batch, index, rInvs := make([]*big.Int, 0, len(cs)), make([]int, 0, len(cs)), make([]*big.Int, 0, len(cs))
+	sub := &FiatBatchKey{PrivateKey: key.PrivateKey}
+	for i, c := range cs {
+		if c.Sign() <= 0 || c.Cmp(key.N) >= 0 || new(big.Int).GCD(nil, nil, c, key.N).Cmp(bigOne) != 0 {
+			errs[i] = ErrDecryption
+			continue
+		}
+		var rInv *big.Int
+		if random != nil {
+			var err error
+			if c, rInv, err = blindBase(random, key.PublicKeyAt(i), c); err != nil {
+				return roots, fillErrors(errs, err)
+			}
+		}
+		batch, index, rInvs = append(batch, c), append(index, i), append(rInvs, rInv)
+		sub.Exponents = append(sub.Exponents, key.Exponents[i])
+	}
+	if len(batch) == 0 {
+		return roots, errs
+	}
+
+	// 2. the full-size root of the tree: v^(1 / e) with e^-1 mod (p_i - 1)
+	tree := sub.fiatTree(batch, 0, len(batch))
+	exps := make([]*big.Int, len(key.Primes))
+	for i, prime := range key.Primes {
+		if exps[i] = modMultiInverse(tree.e, new(big.Int).Sub(prime, bigOne)); exps[i] == nil {
+			return roots, fillErrors(errs, errFiatExponents)
+		}
+	}
+	exps, ybits, err := blindCRTExponents(random, key.PrivateKey, exps)
+	if err != nil {
+		return roots, fillErrors(errs, err)
+	}
+	parts := make([][]big.Word, len(exps))
+	for i := range exps {
+		parts[i] = expCRT(key.PrivateKey, tree.v, i, exps[i], ybits[i])
+	}
+	r := combineCRT(key.PrivateKey, parts)
+
+	// 3. split the root down the tree, check and unblind every root
+	batchRoots := make([]*big.Int, len(batch))
+	if err := sub.fiatSplit(tree, r, batchRoots); err != nil {
+		return roots, fillErrors(errs, err)
+	}
+	for j, i := range index {
+		if encrypt(sub.PublicKeyAt(j), batchRoots[j]).Cmp(batch[j]) != 0 {
+			errs[i] = errInternal
+			continue
+		}
+		roots[i] = batchRoots[j]
+		if rInvs[j] != nil {
+			roots[i] = mulModN(key.PrivateKey, roots[i], rInvs[j])
+		}
+	}
+	return roots, errs
+}
+
+// blindBase returns c * r^E mod N and r^-1 mod N for a random r
+func blindBase(random io.Reader, pub *PublicKey, c *big.Int) (*big.Int, *big.Int, error) {
+	for {
+		r, err := rand.Int(random, pub.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rInv := new(big.Int).ModInverse(r, pub.N); r.Sign() > 0 && rInv != nil {
+			r = encrypt(pub, r)
+			return r.Mul(r, c).Mod(r, pub.N), rInv, nil
+		}
+	}
+}
+
+// SignBatch signs digests[i] with PKCS #1 v1.5 under PublicKeyAt(i).
+// errs[i] is the error of the i-th signature.
+func (key *FiatBatchKey) SignBatch(random io.Reader, hash crypto.Hash, digests [][]byte) (sigs [][]byte, errs []error) {
+	k := key.Size()
+	sigs, errs = make([][]byte, len(digests)), make([]error, len(digests))
+	ms := make([]*big.Int, len(digests))
+	for i, digest := range digests {
+		em, err := emsaPKCS1v15Encode(hash, digest, k)
+		if err != nil {
+			errs[i] = err
+			// 0 is never invertible and is left out of the batch
+			ms[i] = new(big.Int)
+			continue
+		}
+		ms[i] = new(big.Int).SetBytes(em)
+	}
+
+	ss, sErrs := key.fiatRoots(random, ms)
+	for i := range digests {
+		if errs[i] != nil {
+			continue
+		}
+		if errs[i] = sErrs[i]; errs[i] == nil {
+			sigs[i] = ss[i].FillBytes(make([]byte, k))
+		}
+	}
+	return sigs, errs
+}
+
+// DecryptBatch decrypts ciphertexts[i], a PKCS #1 v1.5 ciphertext under
+// PublicKeyAt(i), with implicit rejection like DecryptBatch. errs[i] is the
+// error of the i-th message.
+func (key *FiatBatchKey) DecryptBatch(random io.Reader, ciphertexts [][]byte) (msgs [][]byte, errs []error) {
+	k := key.Size()
+	msgs, errs = make([][]byte, len(ciphertexts)), make([]error, len(ciphertexts))
+	cs := make([]*big.Int, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		cs[i] = new(big.Int)
+		if len(ciphertext) != k || k < 11 {
+			errs[i] = ErrDecryption
+			continue
+		}
+		cs[i].SetBytes(ciphertext)
+	}
+
+	ms, mErrs := key.fiatRoots(random, cs)
+	for i := range ciphertexts {
+		if errs[i] != nil {
+			continue
+		}
+		if errs[i] = mErrs[i]; errs[i] == nil {
+			msgs[i] = implicitRejectionDecode(key.PrivateKey, ciphertexts[i], ms[i].FillBytes(make([]byte, k)))
+		}
+	}
+	return msgs, errs
+}
+
+// VerifyBatchPKCS1v15 verifies sigs[i] of digests[i] like VerifyPKCS1v15 on
+// a pool of goroutines, errs[i] is the error of the i-th signature. With
+// opts.Screen the batch is first screened with a single exponentiation.
+func VerifyBatchPKCS1v15(pub *PublicKey, hash crypto.Hash, digests, sigs [][]byte, opts *BatchOptions) (errs []error) {
+	errs = make([]error, len(digests))
+	if len(sigs) != len(digests) {
+		return fillErrors(errs, ErrVerification)
+	}
+	if opts != nil && opts.Screen && screenPKCS1v15(rand.Reader, pub, hash, digests, sigs) {
+		return errs
+	}
+	parallelFor(len(digests), opts.workers(), func(i int) {
+		errs[i] = VerifyPKCS1v15(pub, hash, digests[i], sigs[i])
+	})
+	return errs
+}
+
+// screenPKCS1v15 reports whether (s_1^r_1 * ... * s_n^r_n)^e ==
+// EM_1^r_1 * ... * EM_n^r_n mod N for 64-bit r_i read from random, the
+// small exponent test of Bellare, Garay and Rabin. A signature that is not
+// of k octets, zero or not less than N fails the screening of the whole
+// batch.
+func screenPKCS1v15(random io.Reader, pub *PublicKey, hash crypto.Hash, digests, sigs [][]byte) bool {
+	if checkPub(pub) != nil || len(digests) == 0 {
+		return false
+	}
+	k := pub.Size()
+	ss, ems := make([]*big.Int, len(digests)), make([]*big.Int, len(digests))
+	for i, digest := range digests {
+		em, err := emsaPKCS1v15Encode(hash, digest, k)
+		if err != nil || len(sigs[i]) != k {
+			return false
+		}
+		if ss[i] = new(big.Int).SetBytes(sigs[i]); ss[i].Sign() == 0 || ss[i].Cmp(pub.N) >= 0 {
+			return false
+		}
+		ems[i] = new(big.Int).SetBytes(em)
+	}
+	rs := make([]byte, 8*len(digests))
+	if _, err := io.ReadFull(random, rs); err != nil {
+		return false
+	}
+
+	// both products are computed at once, sharing the squarings
+	sProd, emProd := new(big.Int).Set(bigOne), new(big.Int).Set(bigOne)
+	for bit := 63; bit >= 0; bit-- {
+		sProd.Mul(sProd, sProd).Mod(sProd, pub.N)
+		emProd.Mul(emProd, emProd).Mod(emProd, pub.N)
+		for i := range ss {
+			if binary.BigEndian.Uint64(rs[8*i:])>>bit&1 == 1 {
+				sProd.Mul(sProd, ss[i]).Mod(sProd, pub.N)
+				emProd.Mul(emProd, ems[i]).Mod(emProd, pub.N)
+			}
+		}
+	}
+	return encrypt(pub, sProd).Cmp(emProd) == 0
+}
+
+// VerifyBatchPSS verifies sigs[i] of digests[i] like VerifyPSS on a pool of
+// goroutines, errs[i] is the error of the i-th signature. The encoded message
+// of PSS depends on the salt, which is only recovered from the signature,
+// so opts.Screen is ignored.
+func VerifyBatchPSS(pub *PublicKey, hash crypto.Hash, digests, sigs [][]byte, pssOpts *PSSOptions, opts *BatchOptions) (errs []error) {
+	errs = make([]error, len(digests))
+	if len(sigs) != len(digests) {
+		return fillErrors(errs, ErrVerification)
+	}
+	parallelFor(len(digests), opts.workers(), func(i int) {
+		errs[i] = VerifyPSS(pub, hash, digests[i], sigs[i], pssOpts)
+	})
+	return errs
+}
@@ -0,0 +1,367 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// fiatExponents returns the first n odd primes usable as Fiat batch exponents of priv
+func fiatExponents(priv *PrivateKey, n int) []int {
+	var exps []int
+	for e := int64(3); len(exps) < n; e += 2 {
+		bigE := big.NewInt(e)
+		if !bigE.ProbablyPrime(20) {
+			continue
+		}
+		ok := true
+		for _, prime := range priv.Primes {
+			if new(big.Int).Mod(prime, bigE).Cmp(bigOne) == 0 {
+				ok = false
+			}
+		}
+		if ok {
+			exps = append(exps, int(e))
+		}
+	}
+	return exps
+}
+
+func batchDigests(n int) [][]byte {
+	digests := make([][]byte, n)
+	for i := range digests {
+		hashed := sha256.Sum256([]byte(fmt.Sprintf("message %d", i)))
+		digests[i] = hashed[:]
+	}
+	return digests
+}
+
+func TestSignBatch(t *testing.T) {
+	for _, nprimes := range []int{2, 3} {
+		priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		noCRT := *priv
+		noCRT.Precomputed = PrecomputedValues{}
+
+		digests := batchDigests(9)
+		digests[4] = digests[4][:5]
+		for _, key := range []*PrivateKey{priv, &noCRT} {
+			for _, workers := range []int{1, 4} {
+				sigs, errs := SignBatch(rand.Reader, key, crypto.SHA256, digests, &BatchOptions{Workers: workers})
+				for i, digest := range digests {
+					if i == 4 {
+						if errs[i] == nil {
+							t.Errorf("%d primes: signed a digest of the wrong length", nprimes)
+						}
+						continue
+					}
+					want, _ := SignPKCS1v15(nil, priv, crypto.SHA256, digest)
+					if errs[i] != nil || !bytes.Equal(sigs[i], want) {
+						t.Errorf("%d primes, %d workers: signature #%d = %x, %v, want %x", nprimes, workers, i, sigs[i], errs[i], want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// a reader that runs out partway fails the rest of the batch, it must not
+// leave entries without a result or an error
+func TestSignBatchShortReader(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCRT := *priv
+	noCRT.Precomputed = PrecomputedValues{}
+	digests := batchDigests(3)
+	for _, key := range []*PrivateKey{priv, &noCRT} {
+		sigs, errs := SignBatch(io.LimitReader(rand.Reader, 200), key, crypto.SHA256, digests, nil)
+		for i := range digests {
+			if (sigs[i] == nil) == (errs[i] == nil) {
+				t.Errorf("signature #%d = %x, %v", i, sigs[i], errs[i])
+			}
+		}
+		if errs[len(digests)-1] == nil {
+			t.Errorf("the last signature did not fail")
+		}
+		msgs, errs := DecryptBatch(io.LimitReader(rand.Reader, 200), key, [][]byte{sigs[0], sigs[0], sigs[0]}, nil)
+		for i := range msgs {
+			if msgs[i] == nil && errs[i] == nil {
+				t.Errorf("message #%d has no result and no error", i)
+			}
+		}
+	}
+}
+
+func TestDecryptBatch(t *testing.T) {
+	priv, err := GenerateMultiPrimeKey(rand.Reader, 3, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := [][]byte{[]byte("a"), []byte("bb"), {}, []byte("dddd")}
+	ciphertexts := make([][]byte, len(msgs)+3)
+	for i, msg := range msgs {
+		if ciphertexts[i], err = EncryptPKCS1v15(rand.Reader, &priv.PublicKey, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a badly padded ciphertext decrypts to its synthetic message
+	bad := encrypt(&priv.PublicKey, bigOne).FillBytes(make([]byte, priv.Size()))
+	synthetic, err := DecryptPKCS1v15ImplicitRejection(nil, priv, bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertexts[len(msgs)] = bad
+	msgs = append(msgs, synthetic)
+	ciphertexts[len(msgs)] = []byte("too short")
+	ciphertexts[len(msgs)+1] = priv.N.FillBytes(make([]byte, priv.Size()))
+
+	got, errs := DecryptBatch(rand.Reader, priv, ciphertexts, nil)
+	for i, msg := range msgs {
+		if errs[i] != nil || !bytes.Equal(got[i], msg) {
+			t.Errorf("#%d: got %q, %v, want %q", i, got[i], errs[i], msg)
+		}
+	}
+	for i := len(msgs); i < len(ciphertexts); i++ {
+		if errs[i] != ErrDecryption || got[i] != nil {
+			t.Errorf("#%d: got %q, %v, want %v", i, got[i], errs[i], ErrDecryption)
+		}
+	}
+}
+
+func TestNewFiatBatchKey(t *testing.T) {
+	priv := test2048Key
+	if _, err := NewFiatBatchKey(priv, []int{3, 5, 15}); err != errFiatExponents {
+		t.Errorf("accepted exponents that are not pairwise coprime: %v", err)
+	}
+	if _, err := NewFiatBatchKey(priv, []int{3, 4}); err != errPublicExponentEven {
+		t.Errorf("accepted an even exponent: %v", err)
+	}
+	if _, err := NewFiatBatchKey(priv, nil); err != errFiatExponents {
+		t.Errorf("accepted no exponents: %v", err)
+	}
+	small := *rsaPrivateKey
+	for _, e := range []int{3, 5, 7, 11, 13} {
+		divides := false
+		for _, prime := range small.Primes {
+			divides = divides || new(big.Int).Mod(prime, big.NewInt(int64(e))).Cmp(bigOne) == 0
+		}
+		if _, err := NewFiatBatchKey(&small, []int{e}); divides != (err != nil) {
+			t.Errorf("e = %d divides p - 1: %v, got %v", e, divides, err)
+		}
+	}
+}
+
+func TestFiatBatch(t *testing.T) {
+	for _, nprimes := range []int{2, 3} {
+		priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := NewFiatBatchKey(priv, fiatExponents(priv, 8))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, n := range []int{1, 2, 5, 8} {
+			digests := batchDigests(n)
+			for _, random := range []io.Reader{nil, rand.Reader} {
+				sigs, errs := key.SignBatch(random, crypto.SHA256, digests)
+				for i, digest := range digests {
+					if errs[i] != nil {
+						t.Errorf("%d primes, batch of %d: signature #%d: %v", nprimes, n, i, errs[i])
+					} else if err := VerifyPKCS1v15(key.PublicKeyAt(i), crypto.SHA256, digest, sigs[i]); err != nil {
+						t.Errorf("%d primes, batch of %d: signature #%d does not verify: %v", nprimes, n, i, err)
+					}
+				}
+			}
+
+			msgs := make([][]byte, n)
+			ciphertexts := make([][]byte, n)
+			for i := range msgs {
+				msgs[i] = []byte(fmt.Sprintf("message %d", i))
+				if ciphertexts[i], err = EncryptPKCS1v15(rand.Reader, key.PublicKeyAt(i), msgs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n > 1 {
+				// a zero ciphertext is left out of the batch
+				ciphertexts[1] = make([]byte, priv.Size())
+			}
+			if n > 2 {
+				// a badly padded ciphertext decrypts to its synthetic message
+				ciphertexts[2] = encrypt(key.PublicKeyAt(2), bigOne).FillBytes(make([]byte, priv.Size()))
+				msgs[2], _ = DecryptPKCS1v15ImplicitRejection(nil, priv, ciphertexts[2])
+			}
+			got, errs := key.DecryptBatch(rand.Reader, ciphertexts)
+			for i, msg := range msgs {
+				if i == 1 {
+					if errs[i] != ErrDecryption {
+						t.Errorf("%d primes, batch of %d: decrypted a zero ciphertext: %v", nprimes, n, errs[i])
+					}
+				} else if errs[i] != nil || !bytes.Equal(got[i], msg) {
+					t.Errorf("%d primes, batch of %d: message #%d = %q, %v, want %q", nprimes, n, i, got[i], errs[i], msg)
+				}
+			}
+		}
+
+		if _, errs := key.SignBatch(nil, crypto.SHA256, batchDigests(9)); errs[0] != errFiatExponents {
+			t.Errorf("signed a batch larger than the exponents: %v", errs[0])
+		}
+	}
+}
+
+func TestFiatBatchFault(t *testing.T) {
+	key, err := NewFiatBatchKey(test2048Key, fiatExponents(test2048Key, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { crtFault = nil }()
+	crtFault = func(i int, mi []big.Word) {
+		mi[0] ^= 1
+	}
+	sigs, errs := key.SignBatch(rand.Reader, crypto.SHA256, batchDigests(4))
+	for i := range sigs {
+		if sigs[i] != nil || errs[i] != errInternal {
+			t.Errorf("signature #%d released after a fault: %v", i, errs[i])
+		}
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	priv := test2048Key
+	pub := &priv.PublicKey
+	digests := batchDigests(10)
+	sigs, errs := SignBatch(rand.Reader, priv, crypto.SHA256, digests, nil)
+	pssSigs := make([][]byte, len(digests))
+	for i, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pssSigs[i], err = SignPSS(rand.Reader, priv, crypto.SHA256, digests[i], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bad[i] breaks the i-th signature
+	bad := map[int]func(sigs [][]byte){
+		1: func(sigs [][]byte) { sigs[1] = append([]byte(nil), sigs[1]...); sigs[1][5] ^= 1 },
+		4: func(sigs [][]byte) { sigs[4] = sigs[4][1:] },
+		7: func(sigs [][]byte) { sigs[7] = sigs[6] },
+	}
+	for _, opts := range []*BatchOptions{nil, {Workers: 1}, {Workers: 3, Screen: true}} {
+		for _, broken := range []bool{false, true} {
+			pkcsSigs, psSigs := append([][]byte(nil), sigs...), append([][]byte(nil), pssSigs...)
+			if broken {
+				for _, f := range bad {
+					f(pkcsSigs)
+					f(psSigs)
+				}
+			}
+			pkcsErrs := VerifyBatchPKCS1v15(pub, crypto.SHA256, digests, pkcsSigs, opts)
+			pssErrs := VerifyBatchPSS(pub, crypto.SHA256, digests, psSigs, nil, opts)
+			for i := range digests {
+				_, isBad := bad[i]
+				isBad = isBad && broken
+				if (pkcsErrs[i] != nil) != isBad {
+					t.Errorf("%+v: PKCS #1 v1.5 signature #%d, broken %v: %v", opts, i, isBad, pkcsErrs[i])
+				}
+				if (pssErrs[i] != nil) != isBad {
+					t.Errorf("%+v: PSS signature #%d, broken %v: %v", opts, i, isBad, pssErrs[i])
+				}
+			}
+		}
+	}
+
+	if errs := VerifyBatchPKCS1v15(pub, crypto.SHA256, digests, sigs[1:], nil); errs[0] != ErrVerification {
+		t.Errorf("verified a batch with a missing signature")
+	}
+}
+
+func TestScreenPKCS1v15(t *testing.T) {
+	priv := test2048Key
+	pub := &priv.PublicKey
+	digests := batchDigests(4)
+	sigs, _ := SignBatch(rand.Reader, priv, crypto.SHA256, digests, nil)
+	if !screenPKCS1v15(rand.Reader, pub, crypto.SHA256, digests, sigs) {
+		t.Errorf("a valid batch failed the screening")
+	}
+
+	// s_0 * x and s_1 / x cancel out in the plain product, not with the
+	// random exponents
+	x := big.NewInt(3)
+	s0 := new(big.Int).SetBytes(sigs[0])
+	s1 := new(big.Int).SetBytes(sigs[1])
+	s0.Mul(s0, x).Mod(s0, pub.N)
+	s1.Mul(s1, new(big.Int).ModInverse(x, pub.N)).Mod(s1, pub.N)
+	forged := [][]byte{s0.FillBytes(make([]byte, pub.Size())), s1.FillBytes(make([]byte, pub.Size())), sigs[2], sigs[3]}
+	if screenPKCS1v15(rand.Reader, pub, crypto.SHA256, digests, forged) {
+		t.Errorf("the forged signatures passed the screening")
+	}
+	for _, opts := range []*BatchOptions{nil, {Screen: true}} {
+		if errs := VerifyBatchPKCS1v15(pub, crypto.SHA256, digests, forged, opts); errs[0] == nil || errs[1] == nil {
+			t.Errorf("%+v: the forged signatures were accepted", opts)
+		}
+	}
+	if screenPKCS1v15(io.LimitReader(rand.Reader, 8), pub, crypto.SHA256, digests, sigs) {
+		t.Errorf("the screening passed without random exponents")
+	}
+
+}
+
+func BenchmarkRSA2048VerifyBatch(b *testing.B) {
+	digests := batchDigests(64)
+	sigs, _ := SignBatch(rand.Reader, test2048Key, crypto.SHA256, digests, nil)
+	pub := &test2048Key.PublicKey
+	b.Run("Single", func(bs *testing.B) {
+		for i := 0; i < bs.N; i++ {
+			for j := range digests {
+				VerifyPKCS1v15(pub, crypto.SHA256, digests[j], sigs[j])
+			}
+		}
+	})
+	for _, opts := range []*BatchOptions{{Workers: 1}, {Workers: 4}, {Workers: 1, Screen: true}} {
+		b.Run(fmt.Sprintf("Workers=%d,Screen=%v", opts.Workers, opts.Screen), func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				VerifyBatchPKCS1v15(pub, crypto.SHA256, digests, sigs, opts)
+			}
+		})
+	}
+}
+
+func BenchmarkRSA2048SignBatch(b *testing.B) {
+	digests := batchDigests(64)
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("Workers=%d", workers), func(bs *testing.B) {
+			opts := &BatchOptions{Workers: workers}
+			start := time.Now()
+			for i := 0; i < bs.N; i++ {
+				SignBatch(rand.Reader, test2048Key, crypto.SHA256, digests, opts)
+			}
+			bs.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(bs.N*len(digests)), "ns/sig")
+		})
+	}
+}
+
+func BenchmarkRSA2048FiatSign(b *testing.B) {
+	key, _ := NewFiatBatchKey(test2048Key, fiatExponents(test2048Key, 8))
+	for _, n := range []int{2, 4, 8} {
+		digests := batchDigests(n)
+		b.Run(fmt.Sprintf("Batch=%d", n), func(bs *testing.B) {
+			start := time.Now()
+			for i := 0; i < bs.N; i++ {
+				key.SignBatch(rand.Reader, crypto.SHA256, digests)
+			}
+			bs.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(bs.N*n), "ns/sig")
+		})
+	}
+}
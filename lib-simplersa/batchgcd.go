@@ -0,0 +1,197 @@
+package lib_simplersa
+
+import (
+	"math/big"
+	"sort"
+
+	"simple-rsa/lib-simplersa/prime"
+)
+
+// productTree returns the levels of the product tree of xs, level 0 is xs
+// and the last level holds the product of all of them
+func productTree(xs []*big.Int) [][]*big.Int {
+	tree := [][]*big.Int{xs}
+	for level := xs; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = new(big.Int).Mul(level[2*i], level[2*i+1])
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		tree = append(tree, next)
+		level = next
+	}
+	return tree
+}
+
+// BatchGCD returns gcd(N_i, ∏_{j≠i} N_j) for every modulus with Bernstein's
+// product tree and remainder tree, in quasi-linear time instead of the
+// quadratic pairwise gcds. A result of 1 means N_i shares no prime with the
+// others, N_i itself means it shares all of them (or is a duplicate). A zero
+// modulus is skipped, its result is 1.
+func BatchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) == 0 {
+		return nil
+	}
+	leaves := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		if leaves[i] = n; n.Sign() == 0 {
+			leaves[i] = bigOne
+		}
+	}
+	moduli = leaves
+	tree := productTree(moduli)
+
+	// descend with P mod x^2 for every node x
+	rems := tree[len(tree)-1]
+	for l := len(tree) - 2; l >= 0; l-- {
+		level := tree[l]
+		next := make([]*big.Int, len(level))
+		sq := new(big.Int)
+		for i, x := range level {
+			next[i] = new(big.Int).Mod(rems[i/2], sq.Mul(x, x))
+		}
+		rems = next
+	}
+
+	gcds := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		// P mod N^2 / N = ∏_{j≠i} N_j mod N
+		q := new(big.Int).Quo(rems[i], n)
+		gcds[i] = q.GCD(nil, nil, q, n)
+	}
+	return gcds
+}
+
+// SharedFactor is a modulus of a corpus that shares primes with others
+type SharedFactor struct {
+	Index  int
+	Key    *PublicKey
+	Factor *big.Int // a nontrivial factor of Key.N
+	// Primes are all primes of Key.N if the corpus factors it completely,
+	// nil otherwise
+	Primes []*big.Int
+	// Private is the key rebuilt from Primes, nil if not factored or E is
+	// not invertible
+	Private *PrivateKey
+}
+
+// DuplicateModulus is a modulus that occurs more than once in a corpus, the
+// same key deployed several times
+type DuplicateModulus struct {
+	N       *big.Int
+	Indices []int // the keys with modulus N, in increasing order
+}
+
+// FindSharedFactors runs BatchGCD on the moduli of keys and returns the keys
+// sharing primes, in the order of keys. Every factor found is used to split
+// the affected moduli further, keys whose primes are all found are rebuilt
+// as private keys. Moduli that occur more than once are reported in
+// duplicates, a key is only in found if it shares a prime with a different
+// modulus. A key without a modulus is an error.
+func FindSharedFactors(keys []*PublicKey) (found []*SharedFactor, duplicates []*DuplicateModulus, err error) {
+	moduli := make([]*big.Int, len(keys))
+	byModulus := make(map[string]*DuplicateModulus)
+	for i, key := range keys {
+		if key == nil || key.N == nil {
+			return nil, nil, errPublicModulus
+		}
+		moduli[i] = key.N
+		d := byModulus[string(key.N.Bytes())]
+		if d == nil {
+			d = &DuplicateModulus{N: key.N}
+			byModulus[string(key.N.Bytes())] = d
+		}
+		if d.Indices = append(d.Indices, i); len(d.Indices) == 2 {
+			duplicates = append(duplicates, d)
+		}
+	}
+	gcds := BatchGCD(moduli)
+
+	var factors []*big.Int
+	for i, g := range gcds {
+		if g.Cmp(bigOne) == 0 {
+			continue
+		}
+		if g.Cmp(moduli[i]) == 0 {
+			// every prime of N_i is shared: split with the other moduli
+			g = nil
+			for j, n := range moduli {
+				if d := new(big.Int).GCD(nil, nil, moduli[i], n); j != i && d.Cmp(bigOne) != 0 && d.Cmp(moduli[i]) != 0 {
+					g = d
+					break
+				}
+			}
+			if g == nil {
+				// only duplicates, reported above
+				continue
+			}
+		}
+		found = append(found, &SharedFactor{Index: i, Key: keys[i], Factor: g})
+		factors = append(factors, g, new(big.Int).Quo(moduli[i], g))
+	}
+
+	for _, f := range found {
+		if f.Primes = splitByFactors(f.Key.N, factors); f.Primes != nil {
+			f.Private = rebuildPrivateKey(f.Key, f.Primes)
+		}
+	}
+	return found, duplicates, nil
+}
+
+// splitByFactors splits n with the gcds of its parts and the factors, it
+// returns the sorted primes of n or nil if a part is left composite
+func splitByFactors(n *big.Int, factors []*big.Int) []*big.Int {
+	parts := []*big.Int{n}
+	for changed := true; changed; {
+		changed = false
+		for _, f := range factors {
+			var next []*big.Int
+			for _, x := range parts {
+				d := new(big.Int).GCD(nil, nil, x, f)
+				if d.Cmp(bigOne) == 0 || d.Cmp(x) == 0 {
+					next = append(next, x)
+					continue
+				}
+				next = append(next, d, new(big.Int).Quo(x, d))
+				changed = true
+			}
+			parts = next
+		}
+	}
+
+	for _, x := range parts {
+		if !prime.ProbablyPrime(x, 20) {
+			return nil
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Cmp(parts[j]) > 0 })
+	return parts
+}
+
+// rebuildPrivateKey returns the private key of pub with the given primes
+func rebuildPrivateKey(pub *PublicKey, primes []*big.Int) *PrivateKey {
+	if len(primes) < 2 || checkPub(pub) != nil {
+		return nil
+	}
+	phiN := new(big.Int).Set(bigOne)
+	for i, p := range primes {
+		// repeated primes are not an RSA modulus
+		if i > 0 && p.Cmp(primes[i-1]) == 0 {
+			return nil
+		}
+		phiN.Mul(phiN, new(big.Int).Sub(p, bigOne))
+	}
+	d := modMultiInverse(big.NewInt(int64(pub.E)), phiN)
+	if d == nil {
+		return nil
+	}
+	priv := &PrivateKey{PublicKey: PublicKey{N: new(big.Int).Set(pub.N), E: pub.E}, D: d, Primes: primes}
+	if priv.Validate() != nil {
+		return nil
+	}
+	priv.Precompute()
+	return priv
+}
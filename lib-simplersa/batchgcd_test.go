@@ -0,0 +1,253 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func TestBatchGCD(t *testing.T) {
+	primes := make([]*big.Int, 12)
+	for i := range primes {
+		primes[i], _ = rand.Prime(rand.Reader, 64)
+	}
+	mul := func(i, j int) *big.Int { return new(big.Int).Mul(primes[i], primes[j]) }
+	moduli := []*big.Int{mul(0, 1), mul(2, 3), mul(0, 4), mul(5, 6), mul(7, 8), mul(6, 9), mul(1, 9), mul(10, 11), mul(10, 11)}
+
+	gcds := BatchGCD(moduli)
+	for i, n := range moduli {
+		want := new(big.Int).Set(bigOne)
+		for j, m := range moduli {
+			if j != i {
+				want.Mul(want, m)
+			}
+		}
+		want.GCD(nil, nil, want, n)
+		if gcds[i].Cmp(want) != 0 {
+			t.Errorf("gcd of modulus %d = %v, want %v", i, gcds[i], want)
+		}
+	}
+	if BatchGCD(nil) != nil || BatchGCD(moduli[:1])[0].Cmp(bigOne) != 0 {
+		t.Errorf("wrong gcds of 0 or 1 modulus")
+	}
+	gcds = BatchGCD([]*big.Int{moduli[0], new(big.Int), moduli[2]})
+	if gcds[0].Cmp(primes[0]) != 0 || gcds[1].Cmp(bigOne) != 0 || gcds[2].Cmp(primes[0]) != 0 {
+		t.Errorf("gcds with a zero modulus = %v", gcds)
+	}
+}
+
+// seededReader returns the seeds first, one after another, then crypto/rand
+func seededReader(seeds ...[]byte) io.Reader {
+	readers := make([]io.Reader, 0, len(seeds)+1)
+	for _, seed := range seeds {
+		readers = append(readers, bytes.NewReader(seed))
+	}
+	return io.MultiReader(append(readers, rand.Reader)...)
+}
+
+// collidingCorpus generates unique keys plus keys that share primes the way
+// keys from a poorly seeded RNG do: GenerateMultiPrimeKey reads the bytes of
+// its first prime from a repeated seed. It returns the corpus and whether
+// the primes of each colliding key are all shared, by index.
+func collidingCorpus(t *testing.T, unique int) ([]*PrivateKey, map[int]bool) {
+	const primeBits = 256
+	seed := func() []byte {
+		b := make([]byte, primeBits/8)
+		rand.Read(b)
+		return b
+	}
+	// gen retries until the key starts with the primes of the seeds, the
+	// same prime for every key of a seed: randomPrime also draws the top
+	// bits from math/rand
+	primeOf := make(map[*byte]*big.Int)
+	gen := func(nprimes int, seeds ...[]byte) *PrivateKey {
+		for {
+			priv, err := GenerateMultiPrimeKey(seededReader(seeds...), nprimes, nprimes*primeBits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok := true
+			for i, s := range seeds {
+				if p, seen := primeOf[&s[0]]; seen {
+					ok = ok && p.Cmp(priv.Primes[i]) == 0
+				} else {
+					p, _ := (&KeyGenOptions{Random: bytes.NewReader(s)}).randomPrime(primeBits)
+					ok = ok && p != nil && p.Cmp(priv.Primes[i]) == 0
+				}
+			}
+			if ok {
+				for i, s := range seeds {
+					primeOf[&s[0]] = priv.Primes[i]
+				}
+				return priv
+			}
+		}
+	}
+
+	var corpus []*PrivateKey
+	colliding := make(map[int]bool)
+	add := func(priv *PrivateKey, shared bool) {
+		colliding[len(corpus)] = shared
+		corpus = append(corpus, priv)
+	}
+	for i := 0; i < unique; i++ {
+		corpus = append(corpus, gen(2))
+	}
+	p, q := seed(), seed()
+	add(gen(2, p), true)
+	add(gen(2, p), true)
+	// a 3-prime key sharing p only, and a key sharing both of its primes
+	add(gen(3, p), false)
+	add(gen(2, q), true)
+	add(gen(2, seed(), q), true)
+	return corpus, colliding
+}
+
+func TestFindSharedFactors(t *testing.T) {
+	corpus, colliding := collidingCorpus(t, 50)
+	// a duplicate of a unique key is reported as a duplicate, not as sharing
+	corpus = append(corpus, corpus[0])
+	keys := make([]*PublicKey, len(corpus))
+	for i, priv := range corpus {
+		keys[i] = &priv.PublicKey
+	}
+
+	found, duplicates, err := FindSharedFactors(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 1 || fmt.Sprint(duplicates[0].Indices) != fmt.Sprint([]int{0, len(keys) - 1}) || duplicates[0].N.Cmp(keys[0].N) != 0 {
+		t.Errorf("duplicates = %+v, want keys 0 and %d", duplicates, len(keys)-1)
+	}
+	if len(found) != len(colliding) {
+		t.Errorf("found %d colliding keys, want %d", len(found), len(colliding))
+	}
+	for _, f := range found {
+		shared, ok := colliding[f.Index]
+		if !ok {
+			t.Errorf("key %d is reported without sharing a prime", f.Index)
+			continue
+		}
+		want := corpus[f.Index]
+		if r := new(big.Int).Mod(want.N, f.Factor); r.Sign() != 0 || f.Factor.Cmp(bigOne) == 0 || f.Factor.Cmp(want.N) == 0 {
+			t.Errorf("key %d: %v is not a factor of N", f.Index, f.Factor)
+		}
+		if !shared {
+			// the other primes stay unknown
+			if f.Private != nil {
+				t.Errorf("key %d: rebuilt from %v without all of its primes", f.Index, f.Primes)
+			}
+			continue
+		}
+		if f.Private == nil {
+			t.Errorf("key %d: the private key of %d primes is not rebuilt", f.Index, len(want.Primes))
+			continue
+		}
+		if f.Private.D.Cmp(want.D) != 0 && new(big.Int).Exp(big.NewInt(42), f.Private.D, want.N).Cmp(new(big.Int).Exp(big.NewInt(42), want.D, want.N)) != 0 {
+			t.Errorf("key %d: the rebuilt D does not match", f.Index)
+		}
+		if _, err := SignPKCS1v15(rand.Reader, f.Private, 0, []byte("rebuilt")); err != nil {
+			t.Errorf("key %d: the rebuilt key can not sign: %s", f.Index, err)
+		}
+	}
+}
+
+func TestFindSharedFactorsMissingModulus(t *testing.T) {
+	key := &PublicKey{N: big.NewInt(3 * 11), E: 3}
+	for _, keys := range [][]*PublicKey{
+		{key, {E: 3}},
+		{nil, key},
+	} {
+		if _, _, err := FindSharedFactors(keys); err != errPublicModulus {
+			t.Errorf("err = %v, want %v", err, errPublicModulus)
+		}
+	}
+}
+
+// sshWire encodes pub as an ssh-rsa key
+func sshWire(pub *PublicKey) string {
+	var b []byte
+	for _, field := range [][]byte{[]byte("ssh-rsa"), big.NewInt(int64(pub.E)).Bytes(), append([]byte{0}, pub.N.Bytes()...)} {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+		b = append(append(b, n[:]...), field...)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	var keys []*PublicKey
+	for i := 0; i < 3; i++ {
+		priv, _ := GenerateKey(rand.Reader, 512)
+		keys = append(keys, &priv.PublicKey)
+	}
+	std := func(pub *PublicKey) *rsa.PublicKey { return &rsa.PublicKey{N: pub.N, E: pub.E} }
+	pkix, _ := x509.MarshalPKIXPublicKey(std(keys[1]))
+	jwkOf := func(pub *PublicKey) map[string]string {
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	}
+	jwkSet, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{jwkOf(keys[0]), map[string]string{"kty": "EC"}, jwkOf(keys[2])}})
+	jwk, _ := json.Marshal(jwkOf(keys[1]))
+
+	for _, test := range []struct {
+		name string
+		data string
+		want []*PublicKey
+	}{
+		{"PEM", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(std(keys[0]))})) +
+			string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})) +
+			string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{1}})), keys[:2]},
+		{"SSH", fmt.Sprintf("# keys\nssh-rsa %s alice@host\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5 bob\nno-pty ssh-rsa %s\n", sshWire(keys[2]), sshWire(keys[0])), []*PublicKey{keys[2], keys[0]}},
+		{"JWK", string(jwk), keys[1:2]},
+		{"JWKSet", string(jwkSet), []*PublicKey{keys[0], keys[2]}},
+	} {
+		got, err := ParsePublicKeys([]byte(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d keys, want %d", test.name, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if got[i].N.Cmp(test.want[i].N) != 0 || got[i].E != test.want[i].E {
+				t.Errorf("%s: key %d does not match", test.name, i)
+			}
+		}
+	}
+
+	for _, data := range []string{"not a key", "ssh-rsa !!!", `{"kty": "RSA", "n": "AQAB"}`, `{"kty": "RSA", "n": "AA", "e": "AQAB"}`, `{"kty": "RSA", "n": "AQA", "e": "AQAB"}`, "ssh-rsa " + base64.StdEncoding.EncodeToString([]byte{0, 0, 0, 7, 's'})} {
+		if _, err := ParsePublicKeys([]byte(data)); err == nil {
+			t.Errorf("ParsePublicKeys(%q): no error", data)
+		}
+	}
+}
+
+func BenchmarkBatchGCD(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		moduli := make([]*big.Int, n)
+		for i := range moduli {
+			moduli[i], _ = rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 2048))
+			moduli[i].SetBit(moduli[i], 0, 1)
+		}
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BatchGCD(moduli)
+			}
+		})
+	}
+}
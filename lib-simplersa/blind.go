@@ -0,0 +1,204 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// RSA blind signatures, RSABSSA of RFC 9474: the client blinds the EMSA-PSS
+// encoding of its message with r^E, the signer signs the blinded message
+// with the raw private operation without learning the message, and the
+// client removes r to get a plain RSASSA-PSS signature.
+//
+//	client                                    signer
+//	prepared = BlindPrepare(msg)
+//	blinded, inv = Blind(prepared)   ---->
+//	                                 <----    blindSig = BlindSign(blinded)
+//	sig = BlindFinalize(prepared, blindSig, inv)
+
+var (
+	errBlindVariant = errors.New("simple_rsa: unknown RSABSSA variant")
+	errBlindMessage = errors.New("simple_rsa: the encoded message is not invertible mod N")
+	errBlindSize    = errors.New("simple_rsa: blinded message or signature of the wrong length")
+)
+
+// BlindVariant is one of the RSABSSA variants of RFC 9474
+type BlindVariant int
+
+const (
+	// BlindSHA384PSSRandomized is RSABSSA-SHA384-PSS-Randomized, the
+	// recommended variant
+	BlindSHA384PSSRandomized BlindVariant = iota
+	// BlindSHA384PSSZeroRandomized is RSABSSA-SHA384-PSSZERO-Randomized
+	BlindSHA384PSSZeroRandomized
+	// BlindSHA384PSSDeterministic is RSABSSA-SHA384-PSS-Deterministic
+	BlindSHA384PSSDeterministic
+	// BlindSHA384PSSZeroDeterministic is RSABSSA-SHA384-PSSZERO-Deterministic,
+	// signatures of a message are unique
+	BlindSHA384PSSZeroDeterministic
+)
+
+func (v BlindVariant) String() string {
+	switch v {
+	case BlindSHA384PSSRandomized:
+		return "RSABSSA-SHA384-PSS-Randomized"
+	case BlindSHA384PSSZeroRandomized:
+		return "RSABSSA-SHA384-PSSZERO-Randomized"
+	case BlindSHA384PSSDeterministic:
+		return "RSABSSA-SHA384-PSS-Deterministic"
+	case BlindSHA384PSSZeroDeterministic:
+		return "RSABSSA-SHA384-PSSZERO-Deterministic"
+	}
+	return "unknown RSABSSA variant"
+}
+
+// saltLength returns sLen of the variant, hLen or 0
+func (v BlindVariant) saltLength() int {
+	if v == BlindSHA384PSSZeroRandomized || v == BlindSHA384PSSZeroDeterministic {
+		return 0
+	}
+	return crypto.SHA384.Size()
+}
+
+// prefixLength returns the length of the random prefix of the variant
+func (v BlindVariant) prefixLength() int {
+	if v == BlindSHA384PSSRandomized || v == BlindSHA384PSSZeroRandomized {
+		return 32
+	}
+	return 0
+}
+
+func (v BlindVariant) check() error {
+	if v < BlindSHA384PSSRandomized || v > BlindSHA384PSSZeroDeterministic {
+		return errBlindVariant
+	}
+	return nil
+}
+
+// BlindPrepare returns the message the client blinds and the verifier
+// checks: msg prefixed with 32 random octets for the randomized variants,
+// msg itself otherwise
+func BlindPrepare(random io.Reader, v BlindVariant, msg []byte) ([]byte, error) {
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	prepared := make([]byte, v.prefixLength(), v.prefixLength()+len(msg))
+	if _, err := io.ReadFull(random, prepared); err != nil {
+		return nil, err
+	}
+	return append(prepared, msg...), nil
+}
+
+// Blind encodes the prepared message with EMSA-PSS and a random salt and
+// blinds it: blindedMsg = EM · r^E mod N. The client keeps inv = r^-1 mod N
+// for BlindFinalize.
+func Blind(random io.Reader, pub *PublicKey, v BlindVariant, prepared []byte) (blindedMsg []byte, inv *big.Int, err error) {
+	if err = checkPub(pub); err != nil {
+		return nil, nil, err
+	}
+	if err = v.check(); err != nil {
+		return nil, nil, err
+	}
+	salt := make([]byte, v.saltLength())
+	if _, err = io.ReadFull(random, salt); err != nil {
+		return nil, nil, err
+	}
+	var r *big.Int
+	for inv == nil {
+		if r, err = randomBelow(random, pub.N); err != nil {
+			return nil, nil, err
+		}
+		if r.Sign() != 0 {
+			inv = modMultiInverse(r, pub.N)
+		}
+	}
+	blindedMsg, err = blind(pub, v, prepared, salt, r)
+	return blindedMsg, inv, err
+}
+
+// blind returns EMSA-PSS-ENCODE(prepared, salt) · r^E mod N
+func blind(pub *PublicKey, v BlindVariant, prepared, salt []byte, r *big.Int) ([]byte, error) {
+	mHash := sha512.Sum384(prepared)
+	em, err := emsaPSSEncode(mHash[:], pub.N.BitLen()-1, salt, sha512.New384(), mgf1(sha512.New384()))
+	if err != nil {
+		return nil, err
+	}
+	m := new(big.Int).SetBytes(em)
+	if new(big.Int).GCD(nil, nil, m, pub.N).Cmp(bigOne) != 0 {
+		return nil, errBlindMessage
+	}
+	z := encrypt(pub, r)
+	z.Mul(z, m).Mod(z, pub.N)
+	return z.FillBytes(make([]byte, pub.Size())), nil
+}
+
+// BlindSign signs a blinded message with the raw private operation, the
+// signer learns nothing of the message. decrypt checks the result against
+// E before it is released.
+func BlindSign(random io.Reader, priv *PrivateKey, blindedMsg []byte) ([]byte, error) {
+	if err := checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	if len(blindedMsg) != priv.Size() {
+		return nil, errBlindSize
+	}
+	m := new(big.Int).SetBytes(blindedMsg)
+	if m.Cmp(priv.N) >= 0 {
+		return nil, errBlindSize
+	}
+	s, err := decrypt(random, priv, m)
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, priv.Size())), nil
+}
+
+// BlindFinalize unblinds the blind signature, sig = blindSig · inv mod N,
+// and returns sig if it is a valid signature of the prepared message
+func BlindFinalize(pub *PublicKey, v BlindVariant, prepared, blindSig []byte, inv *big.Int) ([]byte, error) {
+	if len(blindSig) != pub.Size() {
+		return nil, errBlindSize
+	}
+	s := new(big.Int).SetBytes(blindSig)
+	s.Mul(s, inv).Mod(s, pub.N)
+	sig := s.FillBytes(make([]byte, pub.Size()))
+	if err := BlindVerify(pub, v, prepared, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// BlindVerify verifies the RSASSA-PSS signature of a prepared message with
+// SHA-384, MGF1-SHA-384 and the salt length of the variant
+func BlindVerify(pub *PublicKey, v BlindVariant, prepared, sig []byte) error {
+	if err := checkPub(pub); err != nil {
+		return err
+	}
+	if err := v.check(); err != nil {
+		return err
+	}
+	mHash := sha512.Sum384(prepared)
+	if v.saltLength() > 0 {
+		return verifyPSSWithSalt(pub, sha512.New384(), mgf1(sha512.New384()), mHash[:], sig, v.saltLength())
+	}
+
+	// a salt length of 0 means auto-detection to verifyPSSWithSalt, but
+	// without a salt EMSA-PSS is deterministic: compare the encodings
+	emBits := pub.N.BitLen() - 1
+	want, err := emsaPSSEncode(mHash[:], emBits, nil, sha512.New384(), mgf1(sha512.New384()))
+	if err != nil {
+		return err
+	}
+	if len(sig) != pub.Size() {
+		return ErrVerification
+	}
+	m := encrypt(pub, new(big.Int).SetBytes(sig))
+	if m.BitLen() > len(want)*8 || subtle.ConstantTimeCompare(m.FillBytes(make([]byte, len(want))), want) != 1 {
+		return ErrVerification
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+// rfc9474Vector is a test vector of RFC 9474, Appendix A
+type rfc9474Vector struct {
+	Name       string `json:"name"`
+	P          string `json:"p"`
+	Q          string `json:"q"`
+	N          string `json:"n"`
+	E          string `json:"e"`
+	D          string `json:"d"`
+	Msg        string `json:"msg"`
+	MsgPrefix  string `json:"msg_prefix"`
+	InputMsg   string `json:"input_msg"`
+	Salt       string `json:"salt"`
+	Inv        string `json:"inv"`
+	BlindedMsg string `json:"blinded_msg"`
+	BlindSig   string `json:"blind_sig"`
+	Sig        string `json:"sig"`
+}
+
+// vectorInt parses a 0x prefixed number of the vectors
+func vectorInt(s string) *big.Int {
+	return bigFromHex(strings.TrimPrefix(s, "0x"))
+}
+
+func TestBlindRFC9474(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc9474.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []rfc9474Vector
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	variants := make(map[string]BlindVariant)
+	for v := BlindSHA384PSSRandomized; v <= BlindSHA384PSSZeroDeterministic; v++ {
+		variants[v.String()] = v
+	}
+
+	for _, vec := range vectors {
+		v, ok := variants[vec.Name]
+		if !ok {
+			t.Fatalf("unknown variant %q", vec.Name)
+		}
+		priv := &PrivateKey{
+			PublicKey: PublicKey{N: vectorInt(vec.N), E: int(vectorInt(vec.E).Int64())},
+			D:         vectorInt(vec.D),
+			Primes:    []*big.Int{vectorInt(vec.P), vectorInt(vec.Q)},
+		}
+		if err := priv.Validate(); err != nil {
+			t.Fatalf("%s: %s", vec.Name, err)
+		}
+		priv.Precompute()
+		pub := &priv.PublicKey
+
+		prepared, err := BlindPrepare(bytes.NewReader(fromHex(vec.MsgPrefix)), v, fromHex(vec.Msg))
+		if err != nil || !bytes.Equal(prepared, fromHex(vec.InputMsg)) {
+			t.Errorf("%s: BlindPrepare = %x, %v", vec.Name, prepared, err)
+			continue
+		}
+		inv := vectorInt(vec.Inv)
+		r := new(big.Int).ModInverse(inv, pub.N)
+		blinded, err := blind(pub, v, prepared, fromHex(vec.Salt), r)
+		if err != nil || !bytes.Equal(blinded, fromHex(vec.BlindedMsg)) {
+			t.Errorf("%s: blinded message = %x, %v", vec.Name, blinded, err)
+			continue
+		}
+		blindSig, err := BlindSign(rand.Reader, priv, blinded)
+		if err != nil || !bytes.Equal(blindSig, fromHex(vec.BlindSig)) {
+			t.Errorf("%s: BlindSign = %x, %v", vec.Name, blindSig, err)
+			continue
+		}
+		sig, err := BlindFinalize(pub, v, prepared, blindSig, inv)
+		if err != nil || !bytes.Equal(sig, fromHex(vec.Sig)) {
+			t.Errorf("%s: BlindFinalize = %x, %v", vec.Name, sig, err)
+			continue
+		}
+		if err := BlindVerify(pub, v, prepared, sig); err != nil {
+			t.Errorf("%s: BlindVerify: %s", vec.Name, err)
+		}
+	}
+}
+
+func TestBlindSignatures(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg := []byte("blind me")
+	for v := BlindSHA384PSSRandomized; v <= BlindSHA384PSSZeroDeterministic; v++ {
+		prepared, err := BlindPrepare(rand.Reader, v, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blinded, inv, err := Blind(rand.Reader, pub, v, prepared)
+		if err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+		blindSig, err := BlindSign(rand.Reader, priv, blinded)
+		if err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+		sig, err := BlindFinalize(pub, v, prepared, blindSig, inv)
+		if err != nil {
+			t.Fatalf("%s: %s", v, err)
+		}
+		// a plain RSASSA-PSS signature
+		if v.saltLength() > 0 {
+			digest := sha512.Sum384(prepared)
+			if err := VerifyPSS(pub, crypto.SHA384, digest[:], sig, &PSSOptions{SaltLength: PSSSaltLengthEqualsHash}); err != nil {
+				t.Errorf("%s: VerifyPSS: %s", v, err)
+			}
+		}
+
+		// the signer sees a blinded message unrelated to msg
+		if again, _, _ := Blind(rand.Reader, pub, v, prepared); bytes.Equal(again, blinded) {
+			t.Errorf("%s: blinding the same message twice gives the same value", v)
+		}
+		other, _ := BlindPrepare(rand.Reader, v, []byte("another message"))
+		if BlindVerify(pub, v, other, sig) == nil {
+			t.Errorf("%s: the signature verifies another message", v)
+		}
+		if _, err := BlindFinalize(pub, v, prepared, blindSig, bigOne); err == nil {
+			t.Errorf("%s: finalized with the wrong inverse", v)
+		}
+	}
+	if _, err := BlindSign(rand.Reader, priv, pub.N.Bytes()); err == nil {
+		t.Errorf("BlindSign signed N")
+	}
+	if _, err := BlindPrepare(rand.Reader, BlindVariant(9), msg); err == nil {
+		t.Errorf("BlindPrepare accepted an unknown variant")
+	}
+}
@@ -0,0 +1,162 @@
+package lib_simplersa
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// The functions below work on fixed-width word slices, their running time
+// and memory access pattern only depend on the lengths of their operands,
+// never on the values. They back every operation using D, Dp, Dq or the
+// CRTValues.
+
+// ctMask returns all ones if on == 1, zero if on == 0
+func ctMask(on big.Word) big.Word {
+	return -on
+}
+
+// ctEqWord returns 1 if x == y, 0 otherwise
+func ctEqWord(x, y big.Word) big.Word {
+	d := uint(x ^ y)
+	// d | -d has the top bit set iff d != 0
+	return big.Word(1 ^ (d|-d)>>(_W-1))
+}
+
+// ctSelectWords sets z = x if on == 1, z = y if on == 0
+func ctSelectWords(on big.Word, z, x, y []big.Word) {
+	mask := ctMask(on)
+	for i := range z {
+		z[i] = y[i] ^ (mask & (x[i] ^ y[i]))
+	}
+}
+
+// ctAddWords sets z = x + y and returns the carry, len(x) == len(y) == len(z)
+func ctAddWords(z, x, y []big.Word) big.Word {
+	var c uint
+	for i := range z {
+		var s uint
+		s, c = bits.Add(uint(x[i]), uint(y[i]), c)
+		z[i] = big.Word(s)
+	}
+	return big.Word(c)
+}
+
+// ctSubWords sets z = x - y and returns the borrow, len(x) == len(y) == len(z)
+func ctSubWords(z, x, y []big.Word) big.Word {
+	var b uint
+	for i := range z {
+		var d uint
+		d, b = bits.Sub(uint(x[i]), uint(y[i]), b)
+		z[i] = big.Word(d)
+	}
+	return big.Word(b)
+}
+
+// ctMulWords returns the len(x)+len(y) words of x * y
+func ctMulWords(x, y []big.Word) []big.Word {
+	z := make([]big.Word, len(x)+len(y))
+	for i := range y {
+		var c, cc uint
+		yi := uint(y[i])
+		for j := range x {
+			hi, lo := bits.Mul(uint(x[j]), yi)
+			lo, cc = bits.Add(lo, uint(z[i+j]), 0)
+			hi += cc
+			lo, cc = bits.Add(lo, c, 0)
+			hi += cc
+			z[i+j], c = big.Word(lo), hi
+		}
+		z[i+len(x)] = big.Word(c)
+	}
+	return z
+}
+
+// padWords returns x as exactly n words, x must fit
+func padWords(x []big.Word, n int) []big.Word {
+	z := make([]big.Word, n)
+	copy(z, x)
+	return z
+}
+
+// ctReduce returns x mod m as len(ctx.n) words for an x of any length.
+// The bits of x are shifted in one at a time, z = 2z + bit, z < m holds
+// after a conditional subtraction.
+func (ctx *montContext) ctReduce(x []big.Word) []big.Word {
+	n := len(ctx.n)
+	z, d := make([]big.Word, n), make([]big.Word, n)
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := _W - 1; j >= 0; j-- {
+			// z = 2z + bit, keeping the bit shifted out of the top
+			carry := z[n-1] >> (_W - 1)
+			for k := n - 1; k > 0; k-- {
+				z[k] = z[k]<<1 | z[k-1]>>(_W-1)
+			}
+			z[0] = z[0]<<1 | (x[i]>>uint(j))&1
+
+			// 2z + bit < 2m, subtract m if it is not less than m
+			borrow := ctSubWords(d, z, ctx.n)
+			ctSelectWords(carry|(1^borrow), z, d, z)
+		}
+	}
+	return z
+}
+
+// ctSubMod sets z = x - y mod m, x, y < m
+func (ctx *montContext) ctSubMod(z, x, y []big.Word) {
+	d := make([]big.Word, len(z))
+	borrow := ctSubWords(z, x, y)
+	ctAddWords(d, z, ctx.n)
+	ctSelectWords(borrow, z, d, z)
+}
+
+// ctExpMont returns x^y in Montgomery form, x is in Montgomery form and y
+// is processed as exactly ybits bits. Every window does the same squarings,
+// one multiplication and a lookup that reads the whole table.
+func (ctx *montContext) ctExpMont(x []big.Word, y []big.Word, ybits int) []big.Word {
+	n, t := len(ctx.n), ctx.scratch()
+	nw := (ybits + _W - 1) / _W
+	y = padWords(y, nw)
+
+	// table[i] = x^i
+	var table [1 << montWindow][]big.Word
+	table[0] = ctx.one
+	table[1] = x
+	for i := 2; i < len(table); i++ {
+		table[i] = make([]big.Word, n)
+		ctx.mul(table[i], table[i-1], x, t)
+	}
+
+	z, entry := make([]big.Word, n), make([]big.Word, n)
+	copy(z, ctx.one)
+	for i := nw - 1; i >= 0; i-- {
+		for j := _W - montWindow; j >= 0; j -= montWindow {
+			for k := 0; k < montWindow; k++ {
+				ctx.mul(z, z, z, t)
+			}
+			w := (y[i] >> uint(j)) & (1<<montWindow - 1)
+			for k := range table {
+				ctSelectWords(ctEqWord(w, big.Word(k)), entry, table[k], entry)
+			}
+			ctx.mul(z, z, entry, t)
+		}
+	}
+	return z
+}
+
+// ctExp returns x^y mod m as len(ctx.n) words, x is of any length and y is
+// processed as exactly ybits bits
+func (ctx *montContext) ctExp(x []big.Word, y *big.Int, ybits int) []big.Word {
+	xMont := ctx.ctReduce(x)
+	ctx.mul(xMont, xMont, ctx.rr, ctx.scratch())
+	z := ctx.ctExpMont(xMont, y.Bits(), ybits)
+	return ctx.ctFromMont(z)
+}
+
+// ctFromMont returns z / R mod m
+func (ctx *montContext) ctFromMont(z []big.Word) []big.Word {
+	one := make([]big.Word, len(ctx.n))
+	one[0] = 1
+	x := make([]big.Word, len(ctx.n))
+	ctx.mul(x, z, one, ctx.scratch())
+	return x
+}
@@ -0,0 +1,206 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"flag"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+var dudect = flag.Bool("dudect", false, "run the dudect-style timing tests of the private-key operations")
+
+func TestConstantTimeWords(t *testing.T) {
+	for _, bit := range []int{7, 64, 65, 512, 1024} {
+		m, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, uint(bit)))
+		m.SetBit(m, bit-1, 1).SetBit(m, 0, 1)
+		ctx := newMontContext(m)
+		n := len(ctx.n)
+
+		x, _ := rand.Int(rand.Reader, m)
+		y, _ := rand.Int(rand.Reader, m)
+		big3 := new(big.Int).Lsh(m, uint(3*bit))
+		z, _ := rand.Int(rand.Reader, big3)
+
+		// ctReduce
+		for _, v := range []*big.Int{bigZero, x, m, z, new(big.Int).Sub(big3, bigOne)} {
+			want := new(big.Int).Mod(v, m)
+			if got := new(big.Int).SetBits(ctx.ctReduce(v.Bits())); got.Cmp(want) != 0 {
+				t.Errorf("%d bits: ctReduce(%v) = %v, want %v", bit, v, got, want)
+			}
+		}
+
+		// ctSubMod
+		for _, pair := range [][2]*big.Int{{x, y}, {y, x}, {x, x}, {bigZero, x}} {
+			want := new(big.Int).Sub(pair[0], pair[1])
+			want.Mod(want, m)
+			got := make([]big.Word, n)
+			ctx.ctSubMod(got, ctx.words(pair[0]), ctx.words(pair[1]))
+			if new(big.Int).SetBits(got).Cmp(want) != 0 {
+				t.Errorf("%d bits: ctSubMod(%v, %v) = %v, want %v", bit, pair[0], pair[1], got, want)
+			}
+		}
+
+		// ctMulWords
+		want := new(big.Int).Mul(x, z)
+		if got := new(big.Int).SetBits(ctMulWords(x.Bits(), z.Bits())); got.Cmp(want) != 0 {
+			t.Errorf("%d bits: ctMulWords(%v, %v) = %v, want %v", bit, x, z, got, want)
+		}
+
+		// ctExp, with the exponent padded far beyond its length
+		for _, e := range []*big.Int{bigZero, bigOne, big.NewInt(65537), y} {
+			want := new(big.Int).Exp(z, e, m)
+			got := new(big.Int).SetBits(ctx.ctExp(z.Bits(), e, bit+2*_W))
+			if got.Cmp(want) != 0 {
+				t.Errorf("%d bits: ctExp(%v, %v) = %v, want %v", bit, z, e, got, want)
+			}
+		}
+	}
+}
+
+func TestConstantTimeDecrypt(t *testing.T) {
+	for _, nprimes := range []int{2, 3, 5} {
+		priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, 1024)
+		if err != nil {
+			t.Fatalf("failed to generate a %d-prime key: %s", nprimes, err)
+		}
+		noCRT := *priv
+		noCRT.Precomputed = PrecomputedValues{}
+
+		for _, c := range []*big.Int{bigZero, bigOne, new(big.Int).Sub(priv.N, bigOne), priv.Primes[0]} {
+			want := new(big.Int).Exp(c, priv.D, priv.N)
+			for _, key := range []*PrivateKey{priv, &noCRT} {
+				for _, random := range []io.Reader{nil, rand.Reader} {
+					got, err := decrypt(random, key, c)
+					if err != nil {
+						t.Errorf("%d primes: decrypt(%v): %s", nprimes, c, err)
+					} else if got.Cmp(want) != 0 {
+						t.Errorf("%d primes: decrypt(%v) = %v, want %v", nprimes, c, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// dudectSamples measures f on inputs of class 0 and class 1 in a random
+// interleaved order and returns the two sets of durations
+func dudectSamples(n int, f func(class int)) (samples [2][]float64) {
+	classes := make([]byte, n)
+	rand.Read(classes)
+	for _, b := range classes {
+		class := int(b & 1)
+		start := time.Now()
+		f(class)
+		samples[class] = append(samples[class], float64(time.Since(start)))
+	}
+	return
+}
+
+// welchT returns Welch's t statistic of the two samples
+func welchT(a, b []float64) float64 {
+	meanVar := func(x []float64) (mean, variance float64) {
+		for _, v := range x {
+			mean += v
+		}
+		mean /= float64(len(x))
+		for _, v := range x {
+			variance += (v - mean) * (v - mean)
+		}
+		return mean, variance / float64(len(x)-1)
+	}
+	ma, va := meanVar(a)
+	mb, vb := meanVar(b)
+	return (ma - mb) / math.Sqrt(va/float64(len(a))+vb/float64(len(b)))
+}
+
+// dudectMaxT crops both samples at several percentiles of the merged
+// measurements, as dudect does to drop the interrupted runs, and returns
+// the largest |t|
+func dudectMaxT(samples [2][]float64) float64 {
+	all := append(append([]float64{}, samples[0]...), samples[1]...)
+	sort.Float64s(all)
+	maxT := 0.0
+	for _, pct := range []float64{1, 0.99, 0.9, 0.75, 0.5} {
+		threshold := all[int(pct*float64(len(all)-1))]
+		var cropped [2][]float64
+		for class := range samples {
+			for _, v := range samples[class] {
+				if v <= threshold {
+					cropped[class] = append(cropped[class], v)
+				}
+			}
+		}
+		if len(cropped[0]) < 2 || len(cropped[1]) < 2 {
+			continue
+		}
+		if t := math.Abs(welchT(cropped[0], cropped[1])); t > maxT {
+			maxT = t
+		}
+	}
+	return maxT
+}
+
+// dudectThreshold is the |t| above which a timing difference is reported
+const dudectThreshold = 10
+
+func TestDudectDetectsLeak(t *testing.T) {
+	// expMont skips the zero windows, a zero exponent must stand out
+	m, _ := rand.Prime(rand.Reader, 512)
+	ctx := newMontContext(m)
+	x := ctx.toMont(big.NewInt(3))
+	y, _ := rand.Int(rand.Reader, m)
+	exps := [2]*big.Int{new(big.Int), y}
+	samples := dudectSamples(2000, func(class int) {
+		ctx.expMont(x, exps[class])
+	})
+	if maxT := dudectMaxT(samples); maxT < dudectThreshold {
+		t.Errorf("the harness missed a leaky exponentiation, max |t| = %.2f", maxT)
+	}
+}
+
+func TestDudectPrivateKey(t *testing.T) {
+	if !*dudect {
+		t.Skip("run with -dudect to measure the timing of the private-key operations")
+	}
+	priv, err := GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCRT := *priv
+	noCRT.Precomputed = PrecomputedValues{}
+
+	// class 0 always uses the same ciphertext, class 1 a fresh random one
+	fixed := new(big.Int).Rsh(priv.N, 1)
+	inputs := make([]*big.Int, 0, 4000)
+	for i := 0; i < cap(inputs); i++ {
+		c, _ := rand.Int(rand.Reader, priv.N)
+		inputs = append(inputs, c)
+	}
+
+	for _, test := range []struct {
+		name string
+		priv *PrivateKey
+	}{
+		{"CRT", priv},
+		{"NoCRT", &noCRT},
+	} {
+		next := 0
+		samples := dudectSamples(len(inputs), func(class int) {
+			c := fixed
+			if class == 1 {
+				c = inputs[next]
+				next++
+			}
+			decrypt(nil, test.priv, c)
+		})
+		maxT := dudectMaxT(samples)
+		t.Logf("%s: %d measurements, max |t| = %.2f", test.name, len(inputs), maxT)
+		if maxT > dudectThreshold {
+			t.Errorf("%s: the timing depends on the ciphertext, max |t| = %.2f", test.name, maxT)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package lib_simplersa
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"simple-rsa/lib-simplersa/prime"
+)
+
+// Distributed RSA modulus generation, Boneh and Franklin's "Efficient
+// Generation of Shared RSA Keys" (CRYPTO '97) for honest-but-curious
+// parties: party i picks additive shares p_i, q_i of p = Σ p_i and q = Σ q_i,
+// the parties compute N = p·q with one BGW multiplication over a prime field
+// larger than N, drop N with small factors, and run the biprimality test.
+// No party learns p or q, each keeps its shares for later protocols.
+//
+//	p_0 ≡ q_0 ≡ 3 mod 4, p_i ≡ q_i ≡ 0 mod 4 for i > 0, so p ≡ q ≡ 3 mod 4
+//	N = (Σ p_i)(Σ q_i)                 BGW with Shamir shares of degree (n-1)/2
+//	g^((N - p_0 - q_0 + 1)/4) ≡ ±∏_(i>0) g^((p_i + q_i)/4) mod N
+//	                                   for public g of Jacobi symbol 1
+//	φ(N) mod E ≠ 0                     revealed under a sharing of zero
+//
+// The biprimality test accepts every N = p·q of primes and rejects other
+// N with probability at least 1/2 per g. Boneh and Franklin add a step
+// against N = p^a·q^b, which random shares of honest parties reach with
+// negligible probability, it is left out. The parties need n >= 3 for the
+// product of two degree (n-1)/2 sharings to be interpolated.
+
+var (
+	errDKGParams    = errors.New("simple_rsa: distributed key generation needs at least 3 parties and a 32-bit modulus")
+	errDKGTransport = errors.New("simple_rsa: unexpected message in distributed key generation")
+)
+
+// dkgBiprimalityTests is the number of g of the biprimality test, a
+// product of more than 2 primes passes with probability at most 2^-40
+const dkgBiprimalityTests = 40
+
+// DKGTransport carries the messages of one party, in order for every pair
+// of parties. Parties are numbered from 0.
+type DKGTransport interface {
+	Send(to int, msg []*big.Int) error
+	Receive(from int) ([]*big.Int, error)
+}
+
+// channelTransport connects in-process parties with channels, links[i][j]
+// carries the messages of party i to party j
+type channelTransport struct {
+	index int
+	links [][]chan []*big.Int
+}
+
+// NewChannelTransports returns in-memory transports of parties running in
+// the same process, transports[i] is the one of party i
+func NewChannelTransports(parties int) []DKGTransport {
+	links := make([][]chan []*big.Int, parties)
+	for i := range links {
+		links[i] = make([]chan []*big.Int, parties)
+		for j := range links[i] {
+			// a party is never more than one round ahead of another
+			links[i][j] = make(chan []*big.Int, 2)
+		}
+	}
+	transports := make([]DKGTransport, parties)
+	for i := range transports {
+		transports[i] = &channelTransport{index: i, links: links}
+	}
+	return transports
+}
+
+func (t *channelTransport) Send(to int, msg []*big.Int) error {
+	if to < 0 || to >= len(t.links) || to == t.index {
+		return errDKGTransport
+	}
+	t.links[t.index][to] <- msg
+	return nil
+}
+
+func (t *channelTransport) Receive(from int) ([]*big.Int, error) {
+	if from < 0 || from >= len(t.links) || from == t.index {
+		return nil, errDKGTransport
+	}
+	return <-t.links[from][t.index], nil
+}
+
+// DistributedKey is what a party holds after DistributedKeyGen: the public
+// key and its additive shares of the factors, p = Σ P and q = Σ Q over all
+// parties
+type DistributedKey struct {
+	PublicKey
+	Index int
+	P, Q  *big.Int
+	// Candidates is the number of moduli computed until N
+	Candidates int
+}
+
+// dkgParty is the state of one party during DistributedKeyGen
+type dkgParty struct {
+	transport      DKGTransport
+	index, parties int
+	random         io.Reader
+	field          *big.Int   // the prime of the BGW field
+	lagrange       []*big.Int // the coefficients interpolating 0 from 1..n
+}
+
+// DistributedKeyGen runs the protocol as party index of parties, all of
+// them must call it with the same bits and E. Only Random and E of opts are
+// used.
+func DistributedKeyGen(transport DKGTransport, index, parties, bits int, opts *KeyGenOptions) (*DistributedKey, error) {
+	if opts == nil {
+		opts = &KeyGenOptions{}
+	}
+	// factors of fewer than 16 bits are sieve primes, hasSmallFactor would
+	// reject every candidate
+	if parties < 3 || index < 0 || index >= parties || bits < 32 {
+		return nil, errDKGParams
+	}
+	e, err := opts.publicExponent()
+	if err != nil {
+		return nil, err
+	}
+	party := &dkgParty{transport: transport, index: index, parties: parties, random: opts.random()}
+	party.setupField(bits)
+
+	sizes := []int{(bits + 1) / 2, bits / 2}
+	for candidates := 1; ; candidates++ {
+		p, err := party.factorShare(sizes[0])
+		if err != nil {
+			return nil, err
+		}
+		q, err := party.factorShare(sizes[1])
+		if err != nil {
+			return nil, err
+		}
+		n, err := party.multiply(p, q)
+		if err != nil {
+			return nil, err
+		}
+		// every party sees the same N and takes the same decisions
+		if hasSmallFactor(n) {
+			continue
+		}
+		if ok, err := party.biprime(n, p, q); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if ok, err := party.invertible(n, p, q, e); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return &DistributedKey{PublicKey: PublicKey{N: n, E: e}, Index: index, P: p, Q: q, Candidates: candidates}, nil
+	}
+}
+
+// setupField picks the smallest prime above 2^bits as the BGW field and the
+// Lagrange coefficients ∏_(m≠j) m / (m - j) of the evaluation points 1..n
+func (party *dkgParty) setupField(bits int) {
+	field := new(big.Int).Lsh(bigOne, uint(bits))
+	for field.Add(field, bigOne); !field.ProbablyPrime(20); field.Add(field, bigOne) {
+	}
+	party.field = field
+	party.lagrange = make([]*big.Int, party.parties)
+	for j := 1; j <= party.parties; j++ {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for m := 1; m <= party.parties; m++ {
+			if m != j {
+				num.Mul(num, big.NewInt(int64(m)))
+				den.Mul(den, big.NewInt(int64(m-j)))
+			}
+		}
+		den.Mod(den, field).ModInverse(den, field)
+		party.lagrange[j-1] = num.Mul(num, den).Mod(num, field)
+	}
+}
+
+// factorShare returns the share of a factor of bits bits: the factor is
+// 3·2^(bits-2) plus a sum below 2^(bits-2), party 0 adds the offset and 3
+func (party *dkgParty) factorShare(bits int) (*big.Int, error) {
+	max := new(big.Int).Lsh(bigOne, uint(bits-2))
+	max.Quo(max, big.NewInt(int64(4*party.parties)))
+	s, err := randomBelow(party.random, max)
+	if err != nil {
+		return nil, err
+	}
+	s.Lsh(s, 2)
+	if party.index == 0 {
+		s.Add(s, big.NewInt(3))
+		s.Add(s, new(big.Int).Lsh(big.NewInt(3), uint(bits-2)))
+	}
+	return s, nil
+}
+
+// exchange sends msgs[j] to every other party j and returns what every
+// party sent to this one, msgs[index] at index
+func (party *dkgParty) exchange(msgs [][]*big.Int) ([][]*big.Int, error) {
+	for j, msg := range msgs {
+		if j != party.index {
+			if err := party.transport.Send(j, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	received := make([][]*big.Int, party.parties)
+	received[party.index] = msgs[party.index]
+	for j := range received {
+		if j == party.index {
+			continue
+		}
+		msg, err := party.transport.Receive(j)
+		if err != nil {
+			return nil, err
+		}
+		if len(msg) != len(msgs[party.index]) {
+			return nil, errDKGTransport
+		}
+		received[j] = msg
+	}
+	return received, nil
+}
+
+// broadcast sends msg to every other party
+func (party *dkgParty) broadcast(msg []*big.Int) ([][]*big.Int, error) {
+	msgs := make([][]*big.Int, party.parties)
+	for j := range msgs {
+		msgs[j] = msg
+	}
+	return party.exchange(msgs)
+}
+
+// polynomial returns a random polynomial of the field of the given degree
+// and f(0) = secret
+func (party *dkgParty) polynomial(secret *big.Int, degree int) ([]*big.Int, error) {
+	f := []*big.Int{new(big.Int).Mod(secret, party.field)}
+	for i := 0; i < degree; i++ {
+		a, err := randomBelow(party.random, party.field)
+		if err != nil {
+			return nil, err
+		}
+		f = append(f, a)
+	}
+	return f, nil
+}
+
+// evaluate returns f(x) over the field
+func (party *dkgParty) evaluate(f []*big.Int, x int) *big.Int {
+	y, bx := new(big.Int), big.NewInt(int64(x))
+	for i := len(f) - 1; i >= 0; i-- {
+		y.Mul(y, bx).Add(y, f[i]).Mod(y, party.field)
+	}
+	return y
+}
+
+// multiply returns (Σ a_i)(Σ b_i) of the additive shares a, b of every
+// party. Each party Shamir-shares a_i, b_i of degree t and 0 of degree 2t,
+// party j opens (Σ f_i(j))(Σ g_i(j)) + Σ h_i(j), a point of a polynomial of
+// degree 2t < n of the product.
+func (party *dkgParty) multiply(a, b *big.Int) (*big.Int, error) {
+	t := (party.parties - 1) / 2
+	f, err := party.polynomial(a, t)
+	if err != nil {
+		return nil, err
+	}
+	g, err := party.polynomial(b, t)
+	if err != nil {
+		return nil, err
+	}
+	h, err := party.polynomial(bigZero, 2*t)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([][]*big.Int, party.parties)
+	for j := range msgs {
+		msgs[j] = []*big.Int{party.evaluate(f, j+1), party.evaluate(g, j+1), party.evaluate(h, j+1)}
+	}
+	shares, err := party.exchange(msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	fj, gj, point := new(big.Int), new(big.Int), new(big.Int)
+	for _, share := range shares {
+		fj.Add(fj, share[0])
+		gj.Add(gj, share[1])
+		point.Add(point, share[2])
+	}
+	point.Add(point, fj.Mul(fj, gj)).Mod(point, party.field)
+	points, err := party.broadcast([]*big.Int{point})
+	if err != nil {
+		return nil, err
+	}
+
+	product := new(big.Int)
+	for j, point := range points {
+		product.Add(product, new(big.Int).Mul(point[0], party.lagrange[j]))
+	}
+	return product.Mod(product, party.field), nil
+}
+
+// hasSmallFactor reports whether N is divisible by one of sievePrimes,
+// most candidates stop at the first few primes
+func hasSmallFactor(n *big.Int) bool {
+	for _, p := range sievePrimes {
+		if prime.Residue(n, p) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// publicCoins returns dkgBiprimalityTests values g of Jacobi symbol 1 mod
+// N, hashed from N so that every party agrees without another round
+func publicCoins(n *big.Int) []*big.Int {
+	var coins []*big.Int
+	buf := make([]byte, (n.BitLen()+7)/8+8)
+	for counter := uint64(0); len(coins) < dkgBiprimalityTests; counter++ {
+		n.FillBytes(buf[:len(buf)-8])
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], counter)
+		var g []byte
+		for block := byte(0); len(g) < len(buf); block++ {
+			sum := sha256.Sum256(append([]byte{block}, buf...))
+			g = append(g, sum[:]...)
+		}
+		coin := new(big.Int).SetBytes(g)
+		if coin.Mod(coin, n); big.Jacobi(coin, n) == 1 {
+			coins = append(coins, coin)
+		}
+	}
+	return coins
+}
+
+// biprime runs the biprimality test of Boneh and Franklin: party 0 opens
+// g^((N - p_0 - q_0 + 1)/4), the others g^((p_i + q_i)/4), and N is a
+// product of 2 primes if the first is ± the product of the others for
+// every g
+func (party *dkgParty) biprime(n, p, q *big.Int) (bool, error) {
+	exp := new(big.Int).Add(p, q)
+	if party.index == 0 {
+		exp.Sub(n, exp).Add(exp, bigOne)
+	}
+	exp.Rsh(exp, 2)
+
+	coins := publicCoins(n)
+	values := make([]*big.Int, len(coins))
+	for i, g := range coins {
+		values[i] = new(big.Int).Exp(g, exp, n)
+	}
+	opened, err := party.broadcast(values)
+	if err != nil {
+		return false, err
+	}
+
+	minusOne := new(big.Int).Sub(n, bigOne)
+	for i := range coins {
+		others := big.NewInt(1)
+		for _, v := range opened[1:] {
+			others.Mul(others, v[i]).Mod(others, n)
+		}
+		// v_0 · others^-1 = g^(φ(N)/4) must be ±1
+		inv := new(big.Int).ModInverse(others, n)
+		if inv == nil {
+			return false, nil
+		}
+		r := inv.Mul(inv, opened[0][i]).Mod(inv, n)
+		if r.Cmp(bigOne) != 0 && r.Cmp(minusOne) != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// invertible reports whether E is invertible mod φ(N). Party i shifts its
+// share of φ(N), N - p_0 - q_0 + 1 or -(p_i + q_i), by a random sharing of 0
+// mod E before it is opened, only φ(N) mod E is revealed.
+func (party *dkgParty) invertible(n, p, q *big.Int, e int) (bool, error) {
+	bigE := big.NewInt(int64(e))
+	phi := new(big.Int).Add(p, q)
+	if party.index == 0 {
+		phi.Sub(n, phi).Add(phi, bigOne)
+	} else {
+		phi.Neg(phi)
+	}
+
+	msgs := make([][]*big.Int, party.parties)
+	masked := new(big.Int).Mod(phi, bigE)
+	for j := range msgs {
+		z, err := randomBelow(party.random, bigE)
+		if err != nil {
+			return false, err
+		}
+		if j == party.index {
+			z.SetInt64(0)
+		}
+		msgs[j] = []*big.Int{z}
+		masked.Add(masked, z)
+	}
+	zeros, err := party.exchange(msgs)
+	if err != nil {
+		return false, err
+	}
+	for _, z := range zeros {
+		masked.Sub(masked, z[0])
+	}
+	masked.Mod(masked, bigE)
+
+	opened, err := party.broadcast([]*big.Int{masked})
+	if err != nil {
+		return false, err
+	}
+	sum := new(big.Int)
+	for _, s := range opened {
+		sum.Add(sum, s[0])
+	}
+	return sum.GCD(nil, nil, sum.Mod(sum, bigE), bigE).Cmp(bigOne) == 0, nil
+}
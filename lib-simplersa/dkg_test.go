@@ -0,0 +1,111 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// runDKG runs every party in a goroutine of its own over channels
+func runDKG(t *testing.T, parties, bits int) []*DistributedKey {
+	transports := NewChannelTransports(parties)
+	keys := make([]*DistributedKey, parties)
+	errs := make(chan error, parties)
+	for i := range transports {
+		go func(i int) {
+			var err error
+			keys[i], err = DistributedKeyGen(transports[i], i, parties, bits, nil)
+			errs <- err
+		}(i)
+	}
+	for range transports {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestDistributedKeyGen(t *testing.T) {
+	bits := 512
+	if testing.Short() {
+		// the number of candidates is geometric, about 8000 at 512 bits
+		bits = 384
+	}
+	for _, test := range []struct{ parties, bits int }{{3, bits}, {4, 256}, {5, 256}} {
+		keys := runDKG(t, test.parties, test.bits)
+		pub := &keys[0].PublicKey
+		p, q := new(big.Int), new(big.Int)
+		for _, key := range keys {
+			if key.N.Cmp(pub.N) != 0 || key.Candidates != keys[0].Candidates {
+				t.Fatalf("%d parties: party %d ended with another N", test.parties, key.Index)
+			}
+			p.Add(p, key.P)
+			q.Add(q, key.Q)
+		}
+		if pub.N.BitLen() != test.bits || !p.ProbablyPrime(20) || !q.ProbablyPrime(20) || new(big.Int).Mul(p, q).Cmp(pub.N) != 0 {
+			t.Fatalf("%d parties: N = %x is not the %d-bit product of the shared primes", test.parties, pub.N, test.bits)
+		}
+		t.Logf("%d parties, %d bits: N after %d candidates", test.parties, test.bits, keys[0].Candidates)
+
+		// the public key works with the rest of the library
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+		priv := &PrivateKey{PublicKey: *pub, D: new(big.Int).ModInverse(big.NewInt(int64(pub.E)), phi), Primes: []*big.Int{p, q}}
+		if err := priv.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		priv.Precompute()
+		msg := []byte("jointly generated")
+		c, err := EncryptPKCS1v15(rand.Reader, pub, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := DecryptPKCS1v15(rand.Reader, priv, c); err != nil || !bytes.Equal(got, msg) {
+			t.Errorf("%d parties: DecryptPKCS1v15 = %q, %v", test.parties, got, err)
+		}
+	}
+}
+
+func TestBiprimalityTest(t *testing.T) {
+	// N = p·q·r of primes ≡ 3 mod 4, shared additively as if p = p·q
+	var primes []*big.Int
+	for len(primes) < 3 {
+		p, _ := rand.Prime(rand.Reader, 96)
+		if p.Bit(1) == 1 {
+			primes = append(primes, p)
+		}
+	}
+	n := new(big.Int).Mul(primes[0], primes[1])
+	n.Mul(n, primes[2])
+	transports := NewChannelTransports(3)
+	results := make(chan bool, 3)
+	for i := range transports {
+		go func(i int) {
+			party := &dkgParty{transport: transports[i], index: i, parties: 3, random: rand.Reader}
+			p, q := new(big.Int), new(big.Int)
+			if i == 0 {
+				p.Mul(primes[0], primes[1])
+				q.Set(primes[2])
+			}
+			ok, _ := party.biprime(n, p, q)
+			results <- ok
+		}(i)
+	}
+	for range transports {
+		if <-results {
+			t.Errorf("a product of 3 primes passes the biprimality test")
+		}
+	}
+
+	if _, err := DistributedKeyGen(NewChannelTransports(2)[0], 0, 2, 512, nil); err != errDKGParams {
+		t.Errorf("2 parties: got %v, want %v", err, errDKGParams)
+	}
+	if _, err := DistributedKeyGen(NewChannelTransports(3)[0], 0, 3, 31, nil); err != errDKGParams {
+		t.Errorf("31 bits: got %v, want %v", err, errDKGParams)
+	}
+	// the smallest modulus, both factors just above the sieve primes
+	if keys := runDKG(t, 3, 32); keys[0].N.BitLen() != 32 {
+		t.Errorf("32 bits: N = %v", keys[0].N)
+	}
+}
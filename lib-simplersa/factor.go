@@ -0,0 +1,354 @@
+package lib_simplersa
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"time"
+
+	"simple-rsa/lib-simplersa/prime"
+)
+
+var errFactor = errors.New("simple_rsa: the method found no factor")
+
+// FactorMethod is a factoring algorithm with the parameters used by the
+// attack lab, Split returns a nontrivial factor of n or nil
+type FactorMethod struct {
+	Name  string
+	Split func(random io.Reader, n *big.Int) *big.Int
+}
+
+// FactorMethods are the classic factoring algorithms, bounded so that each
+// of them gives up within seconds
+var FactorMethods = []FactorMethod{
+	{"Trial division", func(_ io.Reader, n *big.Int) *big.Int { return TrialDivision(n, 1<<24) }},
+	{"Fermat", func(_ io.Reader, n *big.Int) *big.Int { return FermatFactor(n, 1<<20) }},
+	{"Pollard rho", func(random io.Reader, n *big.Int) *big.Int { return PollardRho(random, n, 1<<22) }},
+	{"Pollard p-1", func(_ io.Reader, n *big.Int) *big.Int { return PollardPM1(n, 1<<16) }},
+	{"Williams p+1", func(_ io.Reader, n *big.Int) *big.Int { return WilliamsPP1(n, 1<<16) }},
+	{"Lenstra ECM", func(random io.Reader, n *big.Int) *big.Int { return LenstraECM(random, n, 2000, 200) }},
+}
+
+// FactorResult is the outcome of one FactorMethod on a modulus
+type FactorResult struct {
+	Method  string
+	Primes  []*big.Int // sorted, nil if the method failed
+	Elapsed time.Duration
+}
+
+// Factorize splits n completely with m, splitting again every composite
+// part. Prime powers are not split, as no RSA modulus has them.
+func Factorize(random io.Reader, n *big.Int, m FactorMethod) (*FactorResult, error) {
+	start := time.Now()
+	result := &FactorResult{Method: m.Name}
+	todo := []*big.Int{n}
+	for len(todo) > 0 {
+		x := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if prime.ProbablyPrime(x, 20) {
+			result.Primes = append(result.Primes, x)
+			continue
+		}
+		f := m.Split(random, x)
+		if f == nil || f.Cmp(bigOne) <= 0 || f.Cmp(x) >= 0 {
+			result.Primes = nil
+			result.Elapsed = time.Since(start)
+			return result, errFactor
+		}
+		todo = append(todo, f, new(big.Int).Quo(x, f))
+	}
+	sortPrimes(result.Primes)
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
+
+// sortPrimes sorts the primes in decreasing order
+func sortPrimes(primes []*big.Int) {
+	for i := 1; i < len(primes); i++ {
+		for j := i; j > 0 && primes[j].Cmp(primes[j-1]) > 0; j-- {
+			primes[j], primes[j-1] = primes[j-1], primes[j]
+		}
+	}
+}
+
+// primesUpTo returns the primes below bound, 2 included
+func primesUpTo(bound int) []uint32 {
+	return append([]uint32{2}, prime.OddPrimesBelow(bound)...)
+}
+
+// TrialDivision returns the smallest prime factor of n below bound, nil if
+// there is none
+func TrialDivision(n *big.Int, bound int) *big.Int {
+	small, isSmall := n.Uint64(), n.IsUint64()
+	r := new(big.Int)
+	for _, p := range primesUpTo(bound) {
+		var zero bool
+		if isSmall {
+			zero = small%uint64(p) == 0 && small != uint64(p)
+		} else {
+			zero = r.Mod(n, big.NewInt(int64(p))).Sign() == 0
+		}
+		if zero {
+			return big.NewInt(int64(p))
+		}
+	}
+	return nil
+}
+
+// FermatFactor returns p of N = p·q with Fermat's method in at most rounds
+// steps from ceil(sqrt(N)), nil if it fails
+func FermatFactor(n *big.Int, rounds int) *big.Int {
+	if n.Bit(0) == 0 {
+		return nil
+	}
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) == 0 {
+		return a
+	}
+	a.Add(a, bigOne)
+	// b2 = a^2 - N, (a + 1)^2 - N = b2 + 2a + 1
+	b2 := new(big.Int).Mul(a, a)
+	b2.Sub(b2, n)
+	b, step := new(big.Int), new(big.Int)
+	for i := 0; i < rounds; i++ {
+		// squares are 0, 1, 4, 9, 16, 17, 25, 33, 36, 41, 49, 57 mod 64
+		if w := b2.Bits(); len(w) == 0 || uint64(0x0202021202030213)>>(w[0]&63)&1 == 1 {
+			if b.Sqrt(b2); new(big.Int).Mul(b, b).Cmp(b2) == 0 {
+				if p := new(big.Int).Sub(a, b); p.Cmp(bigOne) > 0 {
+					return p
+				}
+				return nil
+			}
+		}
+		step.Lsh(a, 1).Add(step, bigOne)
+		b2.Add(b2, step)
+		a.Add(a, bigOne)
+	}
+	return nil
+}
+
+// PollardRho returns a factor of n with Brent's variant of Pollard's rho,
+// iterating x^2 + c for at most maxIter steps per random c
+func PollardRho(random io.Reader, n *big.Int, maxIter int) *big.Int {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2)
+	}
+	// gcds are taken over products of batch differences
+	const batch = 128
+	g, q, d := new(big.Int), new(big.Int), new(big.Int)
+	f := func(x, c *big.Int) { x.Mul(x, x).Add(x, c).Mod(x, n) }
+	for tries := 0; tries < 8; tries++ {
+		c, err := randomBelow(random, n)
+		if err != nil {
+			return nil
+		}
+		y, err := randomBelow(random, n)
+		if err != nil {
+			return nil
+		}
+		x, ys := new(big.Int), new(big.Int)
+		q.SetInt64(1)
+		g.SetInt64(1)
+		for r, iter := 1, 0; g.Cmp(bigOne) == 0 && iter < maxIter; r *= 2 {
+			x.Set(y)
+			for i := 0; i < r; i++ {
+				f(y, c)
+			}
+			for k := 0; k < r && g.Cmp(bigOne) == 0; k += batch {
+				ys.Set(y)
+				for i := 0; i < batch && i < r-k; i++ {
+					f(y, c)
+					q.Mul(q, d.Sub(x, y).Abs(d)).Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+				iter += batch
+			}
+		}
+		if g.Cmp(n) == 0 {
+			// the batch overshot, step back one difference at a time
+			for {
+				f(ys, c)
+				if g.GCD(nil, nil, d.Sub(x, ys).Abs(d), n); g.Cmp(bigOne) != 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(bigOne) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
+	}
+	return nil
+}
+
+// PollardPM1 returns a factor p of N with a bound-smooth p - 1 using the
+// first stage of Pollard's p - 1 method, nil if it fails
+func PollardPM1(n *big.Int, bound int) *big.Int {
+	if n.Bit(0) == 0 {
+		return nil
+	}
+	a := big.NewInt(2)
+	primes := primesUpTo(bound)
+	// a = 2^M with M the product of the largest prime powers below bound,
+	// taken in batches to keep the exponents long
+	m := big.NewInt(1)
+	for i, p := range primes {
+		m.Mul(m, primePower(p, bound))
+		if m.BitLen() > 4096 || i == len(primes)-1 {
+			a.Exp(a, m, n)
+			m.SetInt64(1)
+		}
+	}
+	g := new(big.Int).Sub(a, bigOne)
+	g.GCD(nil, nil, g, n)
+	if g.Cmp(bigOne) > 0 && g.Cmp(n) < 0 {
+		return g
+	}
+	return nil
+}
+
+// primePower returns the largest power of p below bound
+func primePower(p uint32, bound int) *big.Int {
+	pk := uint64(p)
+	for pk*uint64(p) < uint64(bound) {
+		pk *= uint64(p)
+	}
+	return new(big.Int).SetUint64(pk)
+}
+
+// WilliamsPP1 returns a factor p of N with a bound-smooth p + 1 using the
+// first stage of Williams' p + 1 method with the seeds A = 3, 5, 6, 9: it
+// computes the Lucas sequence V_M(A) and takes gcd(V_M(A) - 2, N). A seed
+// works if A^2 - 4 is a non-residue mod p, otherwise it finds a smooth p - 1.
+// The seeds give A^2 - 4 = 5, 21, 32 and 77, whose quadratic characters
+// mod p are not all the same, so some seed will usually work.
+func WilliamsPP1(n *big.Int, bound int) *big.Int {
+	if n.Bit(0) == 0 {
+		return nil
+	}
+	primes := primesUpTo(bound)
+	g := new(big.Int)
+	for _, seed := range []int64{3, 5, 6, 9} {
+		v := big.NewInt(seed)
+		for _, p := range primes {
+			// V_mk(A) = V_m(V_k(A))
+			v = lucasV(v, primePower(p, bound), n)
+		}
+		g.GCD(nil, nil, new(big.Int).Sub(v, bigTwo), n)
+		if g.Cmp(bigOne) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
+	}
+	return nil
+}
+
+// lucasV returns V_k(a) mod n, V_0 = 2, V_1 = a, V_{i+1} = a V_i - V_{i-1},
+// with the ladder V_2i = V_i^2 - 2, V_2i+1 = V_i V_i+1 - a
+func lucasV(a, k, n *big.Int) *big.Int {
+	x, y := new(big.Int).Set(a), new(big.Int).Mul(a, a)
+	y.Sub(y, bigTwo).Mod(y, n)
+	t := new(big.Int)
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		// (x, y) = (V_j, V_j+1)
+		if k.Bit(i) == 1 {
+			x.Mul(x, y).Sub(x, a).Mod(x, n)
+			y.Mul(y, y).Sub(y, bigTwo).Mod(y, n)
+		} else {
+			y.Mul(x, y).Sub(y, a).Mod(y, n)
+			x.Mul(t.Set(x), x).Sub(x, bigTwo).Mod(x, n)
+		}
+	}
+	return x
+}
+
+// ecmPoint is an affine point of y^2 = x^3 + ax + b mod n, nil is the point
+// at infinity
+type ecmPoint struct {
+	x, y *big.Int
+}
+
+// ecmCurve does the arithmetic of a curve mod the composite n. A slope
+// whose denominator is not invertible records gcd(den, n) in factor.
+type ecmCurve struct {
+	n, a   *big.Int
+	factor *big.Int
+}
+
+func (c *ecmCurve) add(p, q *ecmPoint) *ecmPoint {
+	if p == nil || c.factor != nil {
+		return q
+	}
+	if q == nil {
+		return p
+	}
+	num, den := new(big.Int), new(big.Int)
+	if p.x.Cmp(q.x) == 0 {
+		if sum := new(big.Int).Add(p.y, q.y); sum.Mod(sum, c.n).Sign() == 0 {
+			return nil
+		}
+		// (3x^2 + a) / 2y
+		num.Mul(p.x, p.x).Mul(num, big.NewInt(3)).Add(num, c.a)
+		den.Lsh(p.y, 1)
+	} else {
+		num.Sub(q.y, p.y)
+		den.Sub(q.x, p.x)
+	}
+	den.Mod(den, c.n)
+	inv := new(big.Int).ModInverse(den, c.n)
+	if inv == nil {
+		c.factor = den.GCD(nil, nil, den, c.n)
+		return nil
+	}
+	l := num.Mul(num, inv).Mod(num, c.n)
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, p.x).Sub(x, q.x).Mod(x, c.n)
+	y := new(big.Int).Sub(p.x, x)
+	y.Mul(y, l).Sub(y, p.y).Mod(y, c.n)
+	return &ecmPoint{x, y}
+}
+
+func (c *ecmCurve) mul(k *big.Int, p *ecmPoint) *ecmPoint {
+	var r *ecmPoint
+	for i := k.BitLen() - 1; i >= 0 && c.factor == nil; i-- {
+		r = c.add(r, r)
+		if k.Bit(i) == 1 {
+			r = c.add(r, p)
+		}
+	}
+	return r
+}
+
+// LenstraECM returns a factor of n with the first stage of Lenstra's
+// elliptic curve method on up to curves random curves: a factor p shows up
+// when the order of a curve mod p is bound-smooth
+func LenstraECM(random io.Reader, n *big.Int, bound, curves int) *big.Int {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2)
+	}
+	primes := primesUpTo(bound)
+	for i := 0; i < curves; i++ {
+		// a random curve through a random point, b follows from them
+		x, err := randomBelow(random, n)
+		if err != nil {
+			return nil
+		}
+		y, err := randomBelow(random, n)
+		if err != nil {
+			return nil
+		}
+		a, err := randomBelow(random, n)
+		if err != nil {
+			return nil
+		}
+		c := &ecmCurve{n: n, a: a}
+		p := &ecmPoint{x, y}
+		for _, q := range primes {
+			if p = c.mul(primePower(q, bound), p); p == nil {
+				break
+			}
+		}
+		if c.factor != nil && c.factor.Cmp(n) < 0 && c.factor.Cmp(bigOne) > 0 {
+			return c.factor
+		}
+	}
+	return nil
+}
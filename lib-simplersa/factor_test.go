@@ -0,0 +1,131 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"testing"
+
+	"simple-rsa/lib-simplersa/prime"
+)
+
+// smoothPrime returns a prime p of about bits bits with p + delta twice a
+// product of distinct odd primes below 2^12, delta = ±1
+func smoothPrime(bits int, delta int64) *big.Int {
+	small := prime.OddPrimesBelow(1 << 12)
+	for {
+		x, used := big.NewInt(2), make(map[int64]bool)
+		for x.BitLen() < bits {
+			i, _ := rand.Int(rand.Reader, big.NewInt(int64(len(small))))
+			if !used[i.Int64()] {
+				used[i.Int64()] = true
+				x.Mul(x, big.NewInt(int64(small[i.Int64()])))
+			}
+		}
+		if p := x.Sub(x, big.NewInt(delta)); p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+func TestFactorMethods(t *testing.T) {
+	prime := func(bits int) *big.Int {
+		p, _ := rand.Prime(rand.Reader, bits)
+		return p
+	}
+	closeTo := func(p *big.Int) *big.Int {
+		q := new(big.Int).Add(p, big.NewInt(1<<20))
+		for !q.ProbablyPrime(20) {
+			q.Add(q, bigTwo)
+		}
+		return q
+	}
+	p256 := prime(256)
+	// the seed A = 3 of WilliamsPP1 needs 3^2 - 4 = 5 to be a non-residue
+	pp1 := smoothPrime(200, 1)
+	for big.Jacobi(big.NewInt(5), pp1) != -1 {
+		pp1 = smoothPrime(200, 1)
+	}
+
+	for _, test := range []struct {
+		name  string
+		split func(n *big.Int) *big.Int
+		p, q  *big.Int
+	}{
+		{"TrialDivision", func(n *big.Int) *big.Int { return TrialDivision(n, 1<<20) }, big.NewInt(1000003), prime(200)},
+		{"FermatFactor", func(n *big.Int) *big.Int { return FermatFactor(n, 1<<10) }, p256, closeTo(p256)},
+		{"PollardRho", func(n *big.Int) *big.Int { return PollardRho(rand.Reader, n, 1<<20) }, prime(32), prime(200)},
+		{"PollardPM1", func(n *big.Int) *big.Int { return PollardPM1(n, 1<<12) }, smoothPrime(200, -1), prime(200)},
+		{"WilliamsPP1", func(n *big.Int) *big.Int { return WilliamsPP1(n, 1<<12) }, pp1, prime(200)},
+		{"LenstraECM", func(n *big.Int) *big.Int { return LenstraECM(rand.Reader, n, 2000, 200) }, prime(32), prime(200)},
+	} {
+		n := new(big.Int).Mul(test.p, test.q)
+		f := test.split(n)
+		if f == nil || (f.Cmp(test.p) != 0 && f.Cmp(test.q) != 0) {
+			t.Errorf("%s(%v · %v) = %v", test.name, test.p, test.q, f)
+		}
+	}
+
+	// random 512-bit keys are out of reach
+	priv, _ := GenerateKey(rand.Reader, 512)
+	for _, f := range []*big.Int{TrialDivision(priv.N, 1<<16), FermatFactor(priv.N, 1<<10), PollardPM1(priv.N, 1<<10), WilliamsPP1(priv.N, 1<<10)} {
+		if f != nil {
+			t.Errorf("factored a random 512-bit key: %v", f)
+		}
+	}
+}
+
+func TestFactorize(t *testing.T) {
+	priv, err := GenerateMultiPrimeKey(rand.Reader, 3, 96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rho := FactorMethods[2]
+	result, err := Factorize(rand.Reader, priv.N, rho)
+	if err != nil {
+		t.Fatalf("%s: %s", rho.Name, err)
+	}
+	if len(result.Primes) != 3 {
+		t.Fatalf("%s: got primes %v, want 3", rho.Name, result.Primes)
+	}
+	product := new(big.Int).Set(bigOne)
+	for i, p := range result.Primes {
+		product.Mul(product, p)
+		if i > 0 && p.Cmp(result.Primes[i-1]) > 0 {
+			t.Errorf("%s: the primes are not sorted: %v", rho.Name, result.Primes)
+		}
+	}
+	if product.Cmp(priv.N) != 0 {
+		t.Errorf("%s: the primes %v do not multiply to N", rho.Name, result.Primes)
+	}
+
+	never := FactorMethod{"never", func(_ io.Reader, n *big.Int) *big.Int { return nil }}
+	if result, err = Factorize(rand.Reader, priv.N, never); err == nil || result.Primes != nil {
+		t.Errorf("a failing method returns %v, %v", result.Primes, err)
+	}
+}
+
+func TestLucasV(t *testing.T) {
+	n := big.NewInt(1000003)
+	a := big.NewInt(7)
+	prev, v := big.NewInt(2), new(big.Int).Set(a)
+	for k := int64(1); k < 100; k++ {
+		if got := lucasV(a, big.NewInt(k), n); got.Cmp(v) != 0 {
+			t.Fatalf("V_%d(7) = %v, want %v", k, got, v)
+		}
+		next := new(big.Int).Mul(a, v)
+		next.Sub(next, prev).Mod(next, n)
+		prev, v = v, next
+	}
+}
+
+func BenchmarkFactor64(b *testing.B) {
+	priv, _ := GenerateKey(rand.Reader, 64)
+	for _, m := range FactorMethods[2:] {
+		b.Run(m.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Factorize(rand.Reader, priv.N, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// RSA-FDH, the full domain hash signature of Bellare and Rogaway: the
+// digest is stretched with MGF1 to emBits = bits(N) - 1 bits, so the
+// signed representative is spread over almost all of Z_N instead of the
+// fixed padding of EMSA-PKCS1-v1_5. It is deterministic, as RSA-FDH-VRF of
+// vrf.go, which hashes a domain separated input in the same way.
+
+// emsaFDHEncode returns MGF1(digest) of emLen octets, the top 8emLen - emBits
+// bits cleared
+func emsaFDHEncode(hash crypto.Hash, digest []byte, emBits int) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.New("simple_rsa: unsupported hash function")
+	}
+	if len(digest) != hash.Size() {
+		return nil, errors.New("simple_rsa: input must be hashed message")
+	}
+	em := make([]byte, (emBits+7)/8)
+	if err := mgf1XOR(em, hash.New(), digest); err != nil {
+		return nil, err
+	}
+	em[0] &= 0xff >> uint(8*len(em)-emBits)
+	return em, nil
+}
+
+// SignFDH signs digest with RSA-FDH, MGF1 of hash as the full domain hash
+func SignFDH(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest []byte) (sig []byte, err error) {
+	if err = checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	em, err := emsaFDHEncode(hash, digest, priv.N.BitLen()-1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := decrypt(random, priv, new(big.Int).SetBytes(em))
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, priv.Size())), nil
+}
+
+// VerifyFDH verifies an RSA-FDH signature of digest
+func VerifyFDH(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte) error {
+	if err := checkPub(pub); err != nil {
+		return err
+	}
+	if len(sig) != pub.Size() {
+		return ErrVerification
+	}
+	s := new(big.Int).SetBytes(sig)
+	if s.Cmp(pub.N) >= 0 {
+		return ErrVerification
+	}
+	em, err := emsaFDHEncode(hash, digest, pub.N.BitLen()-1)
+	if err != nil {
+		return err
+	}
+	if encrypt(pub, s).Cmp(new(big.Int).SetBytes(em)) != 0 {
+		return ErrVerification
+	}
+	return nil
+}
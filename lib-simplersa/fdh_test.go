@@ -0,0 +1,44 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestFDH(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	digest := sha256.Sum256([]byte("full domain"))
+	sig, err := SignFDH(rand.Reader, priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFDH(pub, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("VerifyFDH: %s", err)
+	}
+	if again, _ := SignFDH(nil, priv, crypto.SHA256, digest[:]); !bytes.Equal(again, sig) {
+		t.Errorf("RSA-FDH signatures of a digest differ")
+	}
+	// the representative fills bits(N) - 1 bits, not a fixed padding
+	em, _ := emsaFDHEncode(crypto.SHA256, digest[:], priv.N.BitLen()-1)
+	if len(em) != priv.Size() || em[0]&0x80 != 0 {
+		t.Errorf("EM = %x", em)
+	}
+
+	other := sha256.Sum256([]byte("another domain"))
+	if err := VerifyFDH(pub, crypto.SHA256, other[:], sig); err != ErrVerification {
+		t.Errorf("another digest: got %v, want %v", err, ErrVerification)
+	}
+	if err := VerifyFDH(pub, crypto.SHA256, digest[:], sig[1:]); err != ErrVerification {
+		t.Errorf("a short signature: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := SignFDH(rand.Reader, priv, crypto.SHA256, digest[1:]); err == nil {
+		t.Errorf("SignFDH signed a digest of the wrong size")
+	}
+}
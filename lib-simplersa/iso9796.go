@@ -0,0 +1,289 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// ISO/IEC 9796-2 signatures with message recovery: the start of the
+// message, M1, is carried inside the signature and recovered by the
+// verifier, only the rest M2 is sent along. Both schemes use the implicit
+// trailer 0xBC, the hash function is agreed on beforehand.
+//
+// Scheme 1 is deterministic, F = header || M1 || Hash(M1 || M2) || 0xBC of
+// bits(N) bits. The header nibbles are 0x4 for full and 0x6 for partial
+// recovery, then 0xA, or 0xB, 0xBB... and 0xBA when M1 is shorter than the
+// room. It is the format of EMV cards and has known forgeries for partial
+// recovery (Coron, Naccache, Tibouchi, Weinmann 2009), use it for
+// compatibility only.
+//
+// Scheme 2 is randomized like RSASSA-PSS:
+//
+//	H  = Hash(I2OSP(bits(M1), 8) || M1 || Hash(M2) || salt)
+//	DB = 0x00 ... || 0x01 || M1 || salt
+//	F  = (DB ^ MGF1(H)) || H || 0xBC of bits(N) - 1 bits
+
+var (
+	errISO9796Scheme  = errors.New("simple_rsa: unknown ISO/IEC 9796-2 scheme")
+	errISO9796KeySize = errors.New("simple_rsa: ISO/IEC 9796-2 scheme 1 needs a modulus of whole octets")
+)
+
+// ISO9796Options configures ISO/IEC 9796-2 signatures
+type ISO9796Options struct {
+	// Scheme is 1 or 2, defaults to 2
+	Scheme int
+	// SaltLength is the salt length of scheme 2, defaults to the hash size
+	SaltLength int
+}
+
+func (opts *ISO9796Options) scheme() int {
+	if opts == nil || opts.Scheme == 0 {
+		return 2
+	}
+	return opts.Scheme
+}
+
+func (opts *ISO9796Options) saltLength(hash crypto.Hash) int {
+	if opts == nil || opts.SaltLength <= 0 {
+		return hash.Size()
+	}
+	return opts.SaltLength
+}
+
+// ISO9796Capacity returns the number of message octets a signature of pub
+// recovers, longer messages are split into M1 of this length and M2
+func ISO9796Capacity(pub *PublicKey, hash crypto.Hash, opts *ISO9796Options) int {
+	var c int
+	switch opts.scheme() {
+	case 1:
+		// header, hash and trailer
+		c = pub.Size() - hash.Size() - 2
+	case 2:
+		// hash, trailer, 0x01 and salt in emLen octets
+		c = (pub.N.BitLen()+6)/8 - hash.Size() - 2 - opts.saltLength(hash)
+	}
+	if c < 0 {
+		return 0
+	}
+	return c
+}
+
+// checkISO9796 checks the key, hash and options of a signature
+func checkISO9796(pub *PublicKey, hash crypto.Hash, opts *ISO9796Options) error {
+	if err := checkPub(pub); err != nil {
+		return err
+	}
+	if !hash.Available() {
+		return errors.New("simple_rsa: unsupported hash function")
+	}
+	switch opts.scheme() {
+	case 1:
+		if pub.N.BitLen()%8 != 0 {
+			return errISO9796KeySize
+		}
+	case 2:
+	default:
+		return errISO9796Scheme
+	}
+	if ISO9796Capacity(pub, hash, opts) == 0 {
+		return ErrMessageTooLong
+	}
+	return nil
+}
+
+// SignISO9796 signs msg and returns the signature and M2, the part of msg
+// that does not fit the signature and is sent along with it
+func SignISO9796(random io.Reader, priv *PrivateKey, hash crypto.Hash, msg []byte, opts *ISO9796Options) (sig, nonRecoverable []byte, err error) {
+	pub := &priv.PublicKey
+	if err = checkISO9796(pub, hash, opts); err != nil {
+		return nil, nil, err
+	}
+	m1, m2 := msg, []byte(nil)
+	if c := ISO9796Capacity(pub, hash, opts); len(msg) > c {
+		m1, m2 = msg[:c], msg[c:]
+	}
+
+	var f []byte
+	if opts.scheme() == 1 {
+		f = iso9796Scheme1Encode(hash, pub.Size(), m1, m2)
+	} else {
+		salt := make([]byte, opts.saltLength(hash))
+		if _, err = io.ReadFull(random, salt); err != nil {
+			return nil, nil, err
+		}
+		if f, err = iso9796Scheme2Encode(hash, pub.N.BitLen()-1, m1, m2, salt); err != nil {
+			return nil, nil, err
+		}
+	}
+	s, err := decrypt(random, priv, new(big.Int).SetBytes(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.FillBytes(make([]byte, priv.Size())), m2, nil
+}
+
+// VerifyISO9796 verifies the signature of M1 || nonRecoverable and returns
+// the whole message with M1 recovered from the signature
+func VerifyISO9796(pub *PublicKey, hash crypto.Hash, sig, nonRecoverable []byte, opts *ISO9796Options) (msg []byte, err error) {
+	if err = checkISO9796(pub, hash, opts); err != nil {
+		return nil, err
+	}
+	if len(sig) != pub.Size() {
+		return nil, ErrVerification
+	}
+	s := new(big.Int).SetBytes(sig)
+	if s.Cmp(pub.N) >= 0 {
+		return nil, ErrVerification
+	}
+	f := encrypt(pub, s)
+
+	var m1 []byte
+	if opts.scheme() == 1 {
+		m1, err = iso9796Scheme1Decode(hash, f.FillBytes(make([]byte, pub.Size())), nonRecoverable)
+	} else {
+		emBits := pub.N.BitLen() - 1
+		if f.BitLen() > emBits {
+			return nil, ErrVerification
+		}
+		m1, err = iso9796Scheme2Decode(hash, f.FillBytes(make([]byte, (emBits+7)/8)), emBits, nonRecoverable, opts.saltLength(hash))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(m1, nonRecoverable...), nil
+}
+
+// iso9796Scheme1Encode returns F of k octets, M1 fits the capacity
+func iso9796Scheme1Encode(hash crypto.Hash, k int, m1, m2 []byte) []byte {
+	h := hash.New()
+	h.Write(m1)
+	h.Write(m2)
+
+	f := make([]byte, k)
+	f[k-1] = 0xbc
+	hashStart := k - 1 - hash.Size()
+	h.Sum(f[hashStart:hashStart])
+	msgStart := hashStart - len(m1)
+	copy(f[msgStart:], m1)
+
+	header := byte(0x40)
+	if len(m2) > 0 {
+		header = 0x60
+	}
+	if msgStart == 1 {
+		f[0] = header | 0x0a
+		return f
+	}
+	// pad with 0xBB... 0xBA up to M1, the first nibble is still the header
+	for i := 0; i < msgStart; i++ {
+		f[i] = 0xbb
+	}
+	f[msgStart-1] = 0xba
+	f[0] = header | f[0]&0x0f
+	return f
+}
+
+// iso9796Scheme1Decode checks F and returns M1
+func iso9796Scheme1Decode(hash crypto.Hash, f, m2 []byte) ([]byte, error) {
+	k, hLen := len(f), hash.Size()
+	if f[k-1] != 0xbc || f[0]&0xc0 != 0x40 {
+		return nil, ErrVerification
+	}
+	partial := f[0]&0x20 != 0
+	if partial != (len(m2) > 0) {
+		return nil, ErrVerification
+	}
+
+	// the padding nibble 0xB runs until the nibble 0xA
+	msgStart := 1
+	switch f[0] & 0x0f {
+	case 0x0a:
+	case 0x0b:
+		for msgStart < k-1-hLen && f[msgStart] == 0xbb {
+			msgStart++
+		}
+		if msgStart == k-1-hLen || f[msgStart] != 0xba || partial {
+			return nil, ErrVerification
+		}
+		msgStart++
+	default:
+		return nil, ErrVerification
+	}
+
+	m1 := f[msgStart : k-1-hLen]
+	h := hash.New()
+	h.Write(m1)
+	h.Write(m2)
+	if subtle.ConstantTimeCompare(h.Sum(nil), f[k-1-hLen:k-1]) != 1 {
+		return nil, ErrVerification
+	}
+	return append([]byte(nil), m1...), nil
+}
+
+// iso9796Scheme2Hash returns Hash(I2OSP(bits(M1), 8) || M1 || Hash(M2) || salt)
+func iso9796Scheme2Hash(hash crypto.Hash, m1, m2, salt []byte) []byte {
+	h := hash.New()
+	h.Write(m2)
+	m2Hash := h.Sum(nil)
+
+	h.Reset()
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], uint64(len(m1))*8)
+	h.Write(c[:])
+	h.Write(m1)
+	h.Write(m2Hash)
+	h.Write(salt)
+	return h.Sum(nil)
+}
+
+// iso9796Scheme2Encode returns F of emBits bits, M1 fits the capacity
+func iso9796Scheme2Encode(hash crypto.Hash, emBits int, m1, m2, salt []byte) ([]byte, error) {
+	emLen, hLen := (emBits+7)/8, hash.Size()
+	dbLen := emLen - hLen - 1
+	f := make([]byte, emLen)
+	db := f[:dbLen]
+	psLen := dbLen - len(m1) - len(salt) - 1
+	db[psLen] = 0x01
+	copy(db[psLen+1:], m1)
+	copy(db[psLen+1+len(m1):], salt)
+
+	H := iso9796Scheme2Hash(hash, m1, m2, salt)
+	if err := mgf1XOR(db, hash.New(), H); err != nil {
+		return nil, err
+	}
+	db[0] &= 0xff >> uint(8*emLen-emBits)
+	copy(f[dbLen:], H)
+	f[emLen-1] = 0xbc
+	return f, nil
+}
+
+// iso9796Scheme2Decode checks F and returns M1
+func iso9796Scheme2Decode(hash crypto.Hash, f []byte, emBits int, m2 []byte, sLen int) ([]byte, error) {
+	emLen, hLen := len(f), hash.Size()
+	dbLen := emLen - hLen - 1
+	if f[emLen-1] != 0xbc || f[0]&^(0xff>>uint(8*emLen-emBits)) != 0 {
+		return nil, ErrVerification
+	}
+	db := append([]byte(nil), f[:dbLen]...)
+	H := f[dbLen : emLen-1]
+	if err := mgf1XOR(db, hash.New(), H); err != nil {
+		return nil, err
+	}
+	db[0] &= 0xff >> uint(8*emLen-emBits)
+
+	// DB = 0x00 ... || 0x01 || M1 || salt
+	i := bytes.IndexByte(db, 0x01)
+	if i < 0 || !bytes.Equal(db[:i], make([]byte, i)) || dbLen-i-1 < sLen {
+		return nil, ErrVerification
+	}
+	m1, salt := db[i+1:dbLen-sLen], db[dbLen-sLen:]
+	if subtle.ConstantTimeCompare(iso9796Scheme2Hash(hash, m1, m2, salt), H) != 1 {
+		return nil, ErrVerification
+	}
+	return m1, nil
+}
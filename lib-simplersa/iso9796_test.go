@@ -0,0 +1,77 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"testing"
+)
+
+func TestISO9796(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	for _, opts := range []*ISO9796Options{{Scheme: 1}, nil, {Scheme: 2, SaltLength: 20}} {
+		c := ISO9796Capacity(pub, crypto.SHA256, opts)
+		for _, n := range []int{0, 10, c, c + 50} {
+			msg := make([]byte, n)
+			rand.Read(msg)
+			sig, m2, err := SignISO9796(rand.Reader, priv, crypto.SHA256, msg, opts)
+			if err != nil {
+				t.Fatalf("scheme %d, %d octets: %s", opts.scheme(), n, err)
+			}
+			if want := n - c; len(m2) != want && !(want < 0 && len(m2) == 0) {
+				t.Errorf("scheme %d, %d octets: %d octets not recovered", opts.scheme(), n, len(m2))
+			}
+			got, err := VerifyISO9796(pub, crypto.SHA256, sig, m2, opts)
+			if err != nil || !bytes.Equal(got, msg) {
+				t.Errorf("scheme %d, %d octets: VerifyISO9796 = %x, %v", opts.scheme(), n, got, err)
+			}
+			if len(m2) > 0 {
+				m2[0] ^= 1
+				if _, err := VerifyISO9796(pub, crypto.SHA256, sig, m2, opts); err != ErrVerification {
+					t.Errorf("scheme %d: a changed M2: got %v, want %v", opts.scheme(), err, ErrVerification)
+				}
+			}
+		}
+	}
+
+	// scheme 1 headers of padded, full and partial recovery
+	k := priv.Size()
+	c := ISO9796Capacity(pub, crypto.SHA256, &ISO9796Options{Scheme: 1})
+	for _, test := range []struct {
+		m1, m2 int
+		head   []byte
+	}{
+		{c - 2, 0, []byte{0x4b, 0xbb, 0xba}},
+		{c - 1, 0, []byte{0x4b, 0xba}},
+		{c, 0, []byte{0x4a}},
+		{c, 1, []byte{0x6a}},
+	} {
+		f := iso9796Scheme1Encode(crypto.SHA256, k, make([]byte, test.m1), make([]byte, test.m2))
+		if !bytes.HasPrefix(f, append(test.head, 0)) || f[k-1] != 0xbc {
+			t.Errorf("M1 of %d octets, M2 of %d: F = %x...%x", test.m1, test.m2, f[:4], f[k-1])
+		}
+	}
+
+	// scheme 2 is randomized, the other scheme does not verify
+	sig, m2, _ := SignISO9796(rand.Reader, priv, crypto.SHA256, []byte("recover me"), nil)
+	if again, _, _ := SignISO9796(rand.Reader, priv, crypto.SHA256, []byte("recover me"), nil); bytes.Equal(again, sig) {
+		t.Errorf("two scheme 2 signatures are equal")
+	}
+	if _, err := VerifyISO9796(pub, crypto.SHA256, sig, m2, &ISO9796Options{Scheme: 1}); err != ErrVerification {
+		t.Errorf("scheme 2 signature as scheme 1: got %v, want %v", err, ErrVerification)
+	}
+	if _, _, err := SignISO9796(rand.Reader, priv, crypto.SHA256, nil, &ISO9796Options{Scheme: 3}); err != errISO9796Scheme {
+		t.Errorf("scheme 3: got %v, want %v", err, errISO9796Scheme)
+	}
+	odd, _ := GenerateKey(rand.Reader, 1020)
+	if _, _, err := SignISO9796(rand.Reader, odd, crypto.SHA256, nil, &ISO9796Options{Scheme: 1}); err != errISO9796KeySize {
+		t.Errorf("scheme 1 with a 1020-bit key: got %v, want %v", err, errISO9796KeySize)
+	}
+	if _, m2, err := SignISO9796(rand.Reader, odd, crypto.SHA256, []byte("odd"), nil); err != nil || len(m2) != 0 {
+		t.Errorf("scheme 2 with a 1020-bit key: %v", err)
+	}
+}
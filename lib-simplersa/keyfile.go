@@ -0,0 +1,193 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+var (
+	errKeyFormat   = errors.New("simple_rsa: unknown key format")
+	errSSHKey      = errors.New("simple_rsa: malformed ssh-rsa key")
+	errJWK         = errors.New("simple_rsa: malformed RSA JWK")
+	errKeyExponent = errors.New("simple_rsa: public exponent does not fit an int")
+	errKeyModulus  = errors.New("simple_rsa: modulus must be odd and at least 3")
+)
+
+// ParsePublicKeys returns the RSA public keys of a PEM file (PKCS#1 and PKIX
+// public keys, PKCS#1 and PKCS#8 private keys, certificates), an OpenSSH
+// authorized_keys or .pub file, or a JWK / JWK Set. Keys of other
+// algorithms are skipped.
+func ParsePublicKeys(data []byte) ([]*PublicKey, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0:
+		return nil, nil
+	case data[0] == '{' || data[0] == '[':
+		return parseJWK(data)
+	case bytes.Contains(data, []byte("-----BEGIN ")):
+		return parsePEM(data)
+	}
+	return parseSSH(data)
+}
+
+func parsePEM(data []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return keys, nil
+		}
+		data = rest
+
+		var (
+			key interface{}
+			err error
+		)
+		switch block.Type {
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			key = &k.PublicKey
+		}
+		if k, ok := key.(*rsa.PublicKey); ok {
+			keys = append(keys, &PublicKey{N: k.N, E: k.E})
+		}
+	}
+}
+
+// parseSSH parses the ssh-rsa keys of the lines, each "[options] ssh-rsa
+// base64 [comment]" as in authorized_keys
+func parseSSH(data []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	recognized := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := bytes.Fields(line)
+		for i, f := range fields {
+			if bytes.HasPrefix(f, []byte("ssh-")) || bytes.HasPrefix(f, []byte("ecdsa-")) {
+				recognized = true
+			}
+			if string(f) != "ssh-rsa" || i+1 == len(fields) {
+				continue
+			}
+			key, err := parseSSHWire(fields[i+1])
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			break
+		}
+	}
+	if !recognized {
+		return nil, errKeyFormat
+	}
+	return keys, nil
+}
+
+// parseSSHWire parses the RFC 4253 encoding: string "ssh-rsa", mpint e,
+// mpint n
+func parseSSHWire(b64 []byte) (*PublicKey, error) {
+	wire, err := base64.StdEncoding.DecodeString(string(b64))
+	if err != nil {
+		return nil, errSSHKey
+	}
+	next := func() []byte {
+		if len(wire) < 4 || uint64(binary.BigEndian.Uint32(wire)) > uint64(len(wire)-4) {
+			return nil
+		}
+		n := binary.BigEndian.Uint32(wire)
+		field := wire[4 : 4+n]
+		wire = wire[4+n:]
+		return field
+	}
+	if string(next()) != "ssh-rsa" {
+		return nil, errSSHKey
+	}
+	e, n := next(), next()
+	if e == nil || n == nil {
+		return nil, errSSHKey
+	}
+	return newParsedKey(e, n)
+}
+
+type jwk struct {
+	Kty  string `json:"kty"`
+	N    string `json:"n"`
+	E    string `json:"e"`
+	Keys []jwk  `json:"keys"`
+}
+
+// parseJWK parses a JWK, a JWK Set or an array of JWKs
+func parseJWK(data []byte) ([]*PublicKey, error) {
+	var set []jwk
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, err
+		}
+	} else {
+		var k jwk
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, err
+		}
+		set = append(k.Keys, k)
+	}
+
+	var keys []*PublicKey
+	for _, k := range set {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 {
+			return nil, errJWK
+		}
+		key, err := newParsedKey(e, n)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func newParsedKey(e, n []byte) (*PublicKey, error) {
+	bigE := new(big.Int).SetBytes(e)
+	if bigE.BitLen() > 31 {
+		return nil, errKeyExponent
+	}
+	bigN := new(big.Int).SetBytes(n)
+	if bigN.Cmp(bigTwo) <= 0 || bigN.Bit(0) == 0 {
+		return nil, errKeyModulus
+	}
+	return &PublicKey{N: bigN, E: int(bigE.Int64())}, nil
+}
@@ -0,0 +1,178 @@
+package lib_simplersa
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// _W is the size of a big.Word in bits
+const _W = bits.UintSize
+
+// montWindow is the number of exponent bits consumed per table lookup,
+// exponents up to montSmallExpBits bits use plain square-and-multiply
+const (
+	montWindow       = 4
+	montSmallExpBits = 64
+)
+
+// montContext holds the per-modulus constants of Montgomery arithmetic
+// modulo an odd m of n words, with R = 2^(_W * n).
+//
+// Values in Montgomery form are little-endian word slices of exactly n words,
+// x is represented by x * R mod m.
+type montContext struct {
+	m   *big.Int   // modulus
+	n   []big.Word // modulus words
+	k0  big.Word   // -m^(-1) mod 2^_W
+	rr  []big.Word // R^2 mod m
+	one []big.Word // R mod m, 1 in Montgomery form
+}
+
+// newMontContext returns the context of m, or nil if m is even or m <= 1
+func newMontContext(m *big.Int) *montContext {
+	if m.Cmp(bigOne) <= 0 || m.Bit(0) == 0 {
+		return nil
+	}
+	ctx := &montContext{m: new(big.Int).Set(m)}
+	ctx.n = ctx.m.Bits()
+	size := len(ctx.n)
+
+	// Newton's iteration doubles the correct low bits of inv = m^(-1) mod 2^_W
+	// on every step, m * m ≡ 1 mod 8 gives 3 bits to start with.
+	m0 := uint(ctx.n[0])
+	inv := m0
+	for i := 0; i < 6; i++ {
+		inv *= 2 - m0*inv
+	}
+	ctx.k0 = big.Word(-inv)
+
+	r := new(big.Int).Lsh(bigOne, uint(_W*size))
+	ctx.one = ctx.words(r)
+	ctx.rr = ctx.words(r.Mul(r, r))
+	return ctx
+}
+
+// words returns x mod m as exactly len(ctx.n) words
+func (ctx *montContext) words(x *big.Int) []big.Word {
+	z := make([]big.Word, len(ctx.n))
+	if x.Sign() < 0 || x.Cmp(ctx.m) >= 0 {
+		x = new(big.Int).Mod(x, ctx.m)
+	}
+	copy(z, x.Bits())
+	return z
+}
+
+// scratch returns the temporary buffer needed by mul
+func (ctx *montContext) scratch() []big.Word {
+	return make([]big.Word, len(ctx.n)+1)
+}
+
+// mul sets z = x * y / R mod m, z may alias x or y.
+// x, y < m and t is a buffer from ctx.scratch().
+func (ctx *montContext) mul(z, x, y, t []big.Word) {
+	n, m, k0 := len(ctx.n), ctx.n, uint(ctx.k0)
+	for i := range t {
+		t[i] = 0
+	}
+	m, x, t = m[:n], x[:n], t[:n+1]
+
+	// Finely Integrated Operand Scanning: every round adds x * y[i] and
+	// u * m in one pass and shifts t by a word, t < 2m after every round
+	for i := 0; i < n; i++ {
+		yi := uint(y[i])
+
+		// u is chosen that the low word of t + x * y[i] + u * m is 0
+		hi1, lo1 := bits.Mul(uint(x[0]), yi)
+		lo1, cc := bits.Add(lo1, uint(t[0]), 0)
+		c1 := hi1 + cc
+		u := lo1 * k0
+		hi2, lo2 := bits.Mul(u, uint(m[0]))
+		_, cc = bits.Add(lo2, lo1, 0)
+		c2 := hi2 + cc
+
+		for j := 1; j < n; j++ {
+			hi1, lo1 = bits.Mul(uint(x[j]), yi)
+			lo1, cc = bits.Add(lo1, uint(t[j]), 0)
+			hi1 += cc
+			lo1, cc = bits.Add(lo1, c1, 0)
+			c1 = hi1 + cc
+
+			hi2, lo2 = bits.Mul(u, uint(m[j]))
+			lo2, cc = bits.Add(lo2, lo1, 0)
+			hi2 += cc
+			lo2, cc = bits.Add(lo2, c2, 0)
+			c2 = hi2 + cc
+
+			t[j-1] = big.Word(lo2)
+		}
+		s, cc := bits.Add(c1, c2, 0)
+		s, cc2 := bits.Add(s, uint(t[n]), 0)
+		t[n-1], t[n] = big.Word(s), big.Word(cc+cc2)
+	}
+
+	// t < 2m, subtract m unless t < m without branching on the values
+	borrow := ctSubWords(z, t[:n], m)
+	ctSelectWords(t[n]|(1^borrow), z, z, t[:n])
+}
+
+// toMont returns x * R mod m
+func (ctx *montContext) toMont(x *big.Int) []big.Word {
+	z := ctx.words(x)
+	ctx.mul(z, z, ctx.rr, ctx.scratch())
+	return z
+}
+
+// fromMont returns z / R mod m
+func (ctx *montContext) fromMont(z []big.Word) *big.Int {
+	one := make([]big.Word, len(ctx.n))
+	one[0] = 1
+	x := make([]big.Word, len(ctx.n))
+	ctx.mul(x, z, one, ctx.scratch())
+	return new(big.Int).SetBits(x)
+}
+
+// expMont returns x^y in Montgomery form, x is in Montgomery form.
+// It uses fixed windows of montWindow bits and skips the all-zero windows,
+// so its running time depends on y.
+func (ctx *montContext) expMont(x []big.Word, y *big.Int) []big.Word {
+	n, t := len(ctx.n), ctx.scratch()
+
+	// small exponents (e.g. E) do not pay for the table
+	window := montWindow
+	if y.BitLen() <= montSmallExpBits {
+		window = 1
+	}
+
+	// table[i] = x^i
+	table := make([][]big.Word, 1<<window)
+	table[0] = ctx.one
+	table[1] = x
+	for i := 2; i < len(table); i++ {
+		table[i] = make([]big.Word, n)
+		ctx.mul(table[i], table[i-1], x, t)
+	}
+
+	z := make([]big.Word, n)
+	copy(z, ctx.one)
+	started := false
+	yw := y.Bits()
+	for i := len(yw) - 1; i >= 0; i-- {
+		for j := _W - window; j >= 0; j -= window {
+			if started {
+				for k := 0; k < window; k++ {
+					ctx.mul(z, z, z, t)
+				}
+			}
+			if w := (yw[i] >> uint(j)) & (1<<window - 1); w != 0 {
+				ctx.mul(z, z, table[w], t)
+				started = true
+			}
+		}
+	}
+	return z
+}
+
+// exp returns x^y mod m for y >= 0
+func (ctx *montContext) exp(x, y *big.Int) *big.Int {
+	return ctx.fromMont(ctx.expMont(ctx.toMont(x), y))
+}
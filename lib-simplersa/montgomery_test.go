@@ -0,0 +1,95 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestMontgomeryContext(t *testing.T) {
+	for _, m := range []int64{-7, 0, 1, 2, 10} {
+		if newMontContext(big.NewInt(m)) != nil {
+			t.Errorf("got a Montgomery context for m = %d", m)
+		}
+	}
+
+	m := big.NewInt(3)
+	ctx := newMontContext(m)
+	for x := int64(0); x < 3; x++ {
+		if got := ctx.fromMont(ctx.toMont(big.NewInt(x))); got.Int64() != x {
+			t.Errorf("fromMont(toMont(%d)) = %v", x, got)
+		}
+	}
+}
+
+func TestMontgomeryExp(t *testing.T) {
+	bits := []int{7, 64, 65, 127, 512, 1024, 2048}
+	if !testing.Short() {
+		bits = append(bits, 3072, 4096)
+	}
+	for _, bit := range bits {
+		m, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, uint(bit)))
+		m.SetBit(m, bit-1, 1).SetBit(m, 0, 1)
+		ctx := newMontContext(m)
+
+		mMinus1 := new(big.Int).Sub(m, bigOne)
+		x, _ := rand.Int(rand.Reader, m)
+		y, _ := rand.Int(rand.Reader, m)
+		var expTestCases = []struct {
+			x, y *big.Int
+		}{
+			{bigZero, bigZero},
+			{bigZero, y},
+			{bigOne, y},
+			{mMinus1, bigOne},
+			{mMinus1, big.NewInt(2)},
+			{x, bigZero},
+			{x, bigOne},
+			{x, big.NewInt(65537)},
+			{x, y},
+			{new(big.Int).Neg(x), y},
+			{new(big.Int).Add(x, m), y},
+		}
+		for i, test := range expTestCases {
+			want := new(big.Int).Exp(test.x, test.y, m)
+			if test.x.Sign() < 0 {
+				want.Exp(new(big.Int).Mod(test.x, m), test.y, m)
+			}
+			if got := ctx.exp(test.x, test.y); got.Cmp(want) != 0 {
+				t.Errorf("%d bits #%d: exp(%v, %v) mod %v = %v, want %v", bit, i, test.x, test.y, m, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkMontgomeryExp(b *testing.B) {
+	for _, bit := range []int{1024, 2048, 4096} {
+		m, _ := rand.Prime(rand.Reader, bit)
+		x, _ := rand.Int(rand.Reader, m)
+		y, _ := rand.Int(rand.Reader, m)
+		e := big.NewInt(65537)
+		ctx := newMontContext(m)
+
+		b.Run(fmt.Sprintf("Mont/E=65537/%d", bit), func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				ctx.exp(x, e)
+			}
+		})
+		b.Run(fmt.Sprintf("Big/E=65537/%d", bit), func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				new(big.Int).Exp(x, e, m)
+			}
+		})
+		b.Run(fmt.Sprintf("Mont/D/%d", bit), func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				ctx.exp(x, y)
+			}
+		})
+		b.Run(fmt.Sprintf("Big/D/%d", bit), func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				new(big.Int).Exp(x, y, m)
+			}
+		})
+	}
+}
@@ -16,11 +16,30 @@ type OAEPOptions struct {
 	// Label is an arbitrary byte string that must be equal to the value
 	// used when encrypting.
 	Label []byte
+	// MGFHash is the hash function of MGF1, Hash when it is zero. Java
+	// and many HSMs default to MGF1 with SHA-1 whatever Hash is.
+	MGFHash crypto.Hash
+}
+
+// hashes returns new instances of Hash and of the MGF1 hash, nil options
+// have no hash to default to
+func (opts *OAEPOptions) hashes() (h, mgfHash hash.Hash, err error) {
+	if opts == nil {
+		return nil, nil, errors.New("simple_rsa: OAEP needs options with a hash function")
+	}
+	mgf := opts.Hash
+	if opts.MGFHash != 0 {
+		mgf = opts.MGFHash
+	}
+	if !opts.Hash.Available() || !mgf.Available() {
+		return nil, nil, errors.New("simple_rsa: unsupported hash function")
+	}
+	return opts.Hash.New(), mgf.New(), nil
 }
 
 var ErrOAEPRandomSeed = errors.New("simple_rsa: Failed to random seed when EME-OAEP Encoding")
 
-func emeOAEPEncode(hash hash.Hash, random io.Reader, msg []byte, label []byte, k int) (em []byte, err error) {
+func emeOAEPEncode(hash, mgfHash hash.Hash, random io.Reader, msg []byte, label []byte, k int) (em []byte, err error) {
 	mLen, hLen := len(msg), hash.Size()
 
 	// 2. EME-OAEP encoding:
@@ -68,20 +87,20 @@ func emeOAEPEncode(hash hash.Hash, random io.Reader, msg []byte, label []byte, k
 	// 	2.e. dbMask = MGF(seed, k - hLen - 1)
 	// 	2.f. maskedDB = DB XOR dbMask
 	// 	 			  = DB XOR MGF(seed, k - hLen - 1)
-	if err = mgf1XOR(db, hash, seed); err != nil {
+	if err = mgf1XOR(db, mgfHash, seed); err != nil {
 		return
 	}
 
 	// 	2.g. seedMask = MGF(maskedDB, Len)
 	// 	2.h. maskedSeed = seed XOR seedMask
 	//				    = seed XOR MGF(maskedDB, Len)
-	if err = mgf1XOR(seed, hash, db); err != nil {
+	if err = mgf1XOR(seed, mgfHash, db); err != nil {
 		return
 	}
 	return
 }
 
-func emeOAEPDecode(hash hash.Hash, em []byte, label []byte) (msg []byte, err error) {
+func emeOAEPDecode(hash, mgfHash hash.Hash, em []byte, label []byte) (msg []byte, err error) {
 	// 3. EME-OAEP decoding:
 	//	3.a. calc lHash = Hash(L) -> hLen octets
 	hash.Write(label)
@@ -94,13 +113,13 @@ func emeOAEPDecode(hash hash.Hash, em []byte, label []byte) (msg []byte, err err
 	// 	3.c. seedMask = MGF(maskedDB, hLen)
 	//	3.d. seed = maskedSeed XOR seedMask
 	//			  = maskedSeed XOR MGF(maskedDB, hLen)
-	if mgf1XOR(maskedSeed, hash, maskedDB) != nil {
+	if mgf1XOR(maskedSeed, mgfHash, maskedDB) != nil {
 		return nil, ErrDecryption
 	}
 	// 	3.e. dbMask = MFG(seed, k - hLen - 1)
 	//	3.f. DB = maskedDB XOR dbMask
 	//			= maskedDB XOR MFG(seed, k - hLen - 1)
-	if mgf1XOR(maskedDB, hash, maskedSeed) != nil {
+	if mgf1XOR(maskedDB, mgfHash, maskedSeed) != nil {
 		return nil, ErrDecryption
 	}
 
@@ -123,9 +142,6 @@ func emeOAEPDecode(hash hash.Hash, em []byte, label []byte) (msg []byte, err err
 		index = subtle.ConstantTimeSelect(lookingForIndex&valIs1, i+1, index)
 		lookingForIndex = subtle.ConstantTimeSelect(valIs1, 0, lookingForIndex)
 		valid = subtle.ConstantTimeSelect(lookingForIndex&(^valIs0), 0, valid)
-		if lookingForIndex == 0 {
-			break
-		}
 	}
 
 	// check all
@@ -0,0 +1,230 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"testing"
+)
+
+// oracleSeed seeds the padding and the blinding of TestPaddingOracles, with
+// it the Bleichenbacher attack on DecryptPKCS1v15 takes 10831 queries
+const oracleSeed = 1
+
+// budgetExceeded stops an attack that used up its queries
+type budgetExceeded struct{}
+
+// runOracleAttack runs attack against oracle for at most budget queries and
+// returns the block it recovered, nil when it failed or ran out of queries
+func runOracleAttack(budget int, oracle PaddingOracle, attack func(PaddingOracle) ([]byte, int, error)) (em []byte, queries int) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(budgetExceeded); !ok {
+				panic(r)
+			}
+			em = nil
+		}
+	}()
+	limited := func(c []byte) bool {
+		if queries++; queries > budget {
+			panic(budgetExceeded{})
+		}
+		return oracle(c)
+	}
+	em, _, err := attack(limited)
+	if err != nil {
+		return nil, queries
+	}
+	return em, queries
+}
+
+// observation is what the caller of a decryption learns from its return
+// values: the error or the length of the message
+func observation(msg []byte, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%d octets", len(msg))
+}
+
+// derivedOracle calibrates observe on the encryptions of known blocks: an
+// observation only ever seen on blocks for which bit holds answers true, any
+// other answers false. It is the best oracle the return values give away.
+func derivedOracle(pub *PublicKey, observe func(c []byte) string, bit func(em []byte) bool, blocks [][]byte) PaddingOracle {
+	seen := make(map[string][2]bool)
+	for _, em := range blocks {
+		c := encrypt(pub, new(big.Int).SetBytes(em)).FillBytes(make([]byte, pub.Size()))
+		s, b := seen[observe(c)], 0
+		if bit(em) {
+			b = 1
+		}
+		s[b] = true
+		seen[observe(c)] = s
+	}
+	return func(c []byte) bool {
+		s := seen[observe(c)]
+		return s[1] && !s[0]
+	}
+}
+
+// calibrationBlocks returns valid and random blocks 0x00 || 0x02 || … and
+// 0x01 || 0x02 || … of k bytes
+func calibrationBlocks(k int, valid ...[]byte) [][]byte {
+	blocks := valid
+	for i := 0; i < 64; i++ {
+		em := make([]byte, k)
+		rand.Read(em[2:])
+		// Y = 1 stays below N
+		em[i%2], em[1] = 1, 2
+		blocks = append(blocks, em)
+	}
+	return blocks
+}
+
+func TestPaddingOracles(t *testing.T) {
+	// the number of queries of the Bleichenbacher attack varies a lot with
+	// the key, the padding and the blinding: fix all of them, so the leaky
+	// oracle is broken within its budget on every run
+	priv, rng := rsaPrivateKey, mrand.New(mrand.NewSource(oracleSeed))
+	pub, k := &priv.PublicKey, priv.Size()
+	label := []byte("oracle")
+	msg := []byte("attack at dawn")
+
+	v15 := make([][]byte, 16)
+	oaep := make([][]byte, 16)
+	for i := range v15 {
+		v15[i] = make([]byte, k)
+		v15[i][1] = 2
+		nonZeroRandomBytes(rng, v15[i][2:k-len(msg)-1])
+		copy(v15[i][k-len(msg):], msg)
+		oaep[i], _ = emeOAEPEncode(sha1.New(), sha1.New(), rng, msg, label, k)
+	}
+	conforming := func(em []byte) bool {
+		_, err := emePKCS1v15Decode(append([]byte{}, em...))
+		return err == nil
+	}
+	yIsZero := func(em []byte) bool { return em[0] == 0 }
+
+	// only whether DecryptPKCS1v15 failed, calibrating on the lengths too
+	// would answer false for every length the calibration did not see
+	decryptV15 := func(c []byte) string {
+		_, err := DecryptPKCS1v15(nil, priv, c)
+		return observation(nil, err)
+	}
+	implicit := func(c []byte) string { return observation(DecryptPKCS1v15ImplicitRejection(nil, priv, c)) }
+	// the batch decrypters are observed like DecryptPKCS1v15
+	batch := func(c []byte) string {
+		_, errs := DecryptBatch(nil, priv, [][]byte{c}, nil)
+		return observation(nil, errs[0])
+	}
+	fiat, err := NewFiatBatchKey(priv, []int{priv.E})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fiatBatch := func(c []byte) string {
+		_, errs := fiat.DecryptBatch(nil, [][]byte{c})
+		return observation(nil, errs[0])
+	}
+	sessionKey := func(c []byte) string {
+		key := make([]byte, len(msg))
+		return observation(key, DecryptPKCS1v15SessionKey(nil, priv, c, key))
+	}
+	decryptOAEP := func(c []byte) string { return observation(DecryptOAEP(sha1.New(), nil, priv, c, label)) }
+	// the classic mistake: Y is checked first and fails on its own
+	earlyY := func(c []byte) string {
+		m, _ := decrypt(nil, priv, new(big.Int).SetBytes(c))
+		if m.BitLen() > 8*(k-1) {
+			return "Y != 0"
+		}
+		return decryptOAEP(c)
+	}
+
+	bleichenbacher := func(o PaddingOracle) ([]byte, int, error) {
+		return BleichenbacherAttack(mrand.New(mrand.NewSource(oracleSeed)), pub, encrypt(pub, new(big.Int).SetBytes(v15[0])).FillBytes(make([]byte, k)), o)
+	}
+	manger := func(o PaddingOracle) ([]byte, int, error) {
+		return MangerAttack(pub, encrypt(pub, new(big.Int).SetBytes(oaep[0])).FillBytes(make([]byte, k)), o)
+	}
+
+	for _, test := range []struct {
+		name    string
+		observe func(c []byte) string
+		bit     func(em []byte) bool
+		blocks  [][]byte
+		attack  func(PaddingOracle) ([]byte, int, error)
+		want    []byte
+		budget  int
+		// leaks marks the oracles the harness must break
+		leaks bool
+	}{
+		// DecryptPKCS1v15 reports the padding error by design
+		{"DecryptPKCS1v15/Bleichenbacher", decryptV15, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 15, true},
+		{"ImplicitRejection/Bleichenbacher", implicit, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 12, false},
+		{"DecryptBatch/Bleichenbacher", batch, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 15, false},
+		{"FiatBatchKey.DecryptBatch/Bleichenbacher", fiatBatch, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 15, false},
+		{"SessionKey/Bleichenbacher", sessionKey, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 12, false},
+		{"DecryptOAEP/Bleichenbacher", decryptOAEP, conforming, calibrationBlocks(k, v15...), bleichenbacher, v15[0], 1 << 12, false},
+		{"DecryptOAEP/Manger", decryptOAEP, yIsZero, calibrationBlocks(k, oaep...), manger, oaep[0], 1 << 12, false},
+		{"EarlyY/Manger", earlyY, yIsZero, calibrationBlocks(k, oaep...), manger, oaep[0], 1 << 12, true},
+	} {
+		oracle := derivedOracle(pub, test.observe, test.bit, test.blocks)
+		em, queries := runOracleAttack(test.budget, oracle, test.attack)
+		broken := bytes.Equal(em, test.want)
+		t.Logf("%s: %d queries, broken: %t", test.name, queries, broken)
+		if broken != test.leaks {
+			if test.leaks {
+				t.Errorf("%s: the harness failed to exploit a leaky oracle in %d queries", test.name, queries)
+			} else {
+				t.Errorf("%s: the plaintext is recovered with %d oracle queries", test.name, queries)
+			}
+		}
+	}
+}
+
+func TestPaddingDecoderTiming(t *testing.T) {
+	if !*dudect {
+		t.Skip("run with -dudect to measure the timing of the padding decoders")
+	}
+	const k = 256
+	msg := []byte("attack at dawn")
+	label := []byte("oracle")
+	v15 := func(psLen int) []byte {
+		em := make([]byte, k)
+		em[1] = 2
+		nonZeroRandomBytes(rand.Reader, em[2:2+psLen])
+		rand.Read(em[3+psLen:])
+		return em
+	}
+	oaep := func(msg []byte, y byte) []byte {
+		em, _ := emeOAEPEncode(sha1.New(), sha1.New(), rand.Reader, msg, label, k)
+		em[0] = y
+		return em
+	}
+	h := sha1.New()
+
+	for _, test := range []struct {
+		name    string
+		classes [2][]byte
+		decode  func(em []byte)
+	}{
+		{"PKCS1v15/Separator", [2][]byte{v15(8), v15(k - 4)}, func(em []byte) { emePKCS1v15Decode(em) }},
+		{"PKCS1v15/Header", [2][]byte{v15(100), append([]byte{0, 1}, v15(100)[2:]...)}, func(em []byte) { emePKCS1v15Decode(em) }},
+		{"OAEP/Y", [2][]byte{oaep(msg, 0), oaep(msg, 1)}, func(em []byte) { emeOAEPDecode(h, h, em, label) }},
+		{"OAEP/Separator", [2][]byte{oaep(nil, 0), oaep(make([]byte, k-2*h.Size()-2), 0)}, func(em []byte) { emeOAEPDecode(h, h, em, label) }},
+	} {
+		buf := make([]byte, k)
+		samples := dudectSamples(20000, func(class int) {
+			// emeOAEPDecode unmasks in place
+			copy(buf, test.classes[class])
+			test.decode(buf)
+		})
+		maxT := dudectMaxT(samples)
+		t.Logf("%s: max |t| = %.2f", test.name, maxT)
+		if maxT > dudectThreshold {
+			t.Errorf("%s: the decoding time tells the classes apart, max |t| = %.2f", test.name, maxT)
+		}
+	}
+}
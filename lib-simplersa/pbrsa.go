@@ -0,0 +1,251 @@
+package lib_simplersa
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Partially blind RSA signatures with public metadata
+// (draft-amjad-cfrg-partially-blind-rsa): the signer still does not see the
+// message, but both sides bind a public metadata value into the signature.
+// The key pair of a metadata value has the exponent E′ derived from N and
+// the metadata with HKDF, and D′ = E′^-1 mod φ(N). A signature made for one
+// metadata value does not verify under any other. φ(N) = 4p′q′ of safe
+// primes p = 2p′ + 1 and q = 2q′ + 1 keeps every odd E′ below p′, q′
+// invertible.
+//
+// The message is blinded as in RFC 9474 with E′, prepared with BlindPrepare
+// if the randomized variant is wanted, and signed with RSASSA-PSS, SHA-384
+// and a 48-octet salt.
+
+var (
+	errPartiallyBlindKey = errors.New("simple_rsa: partially blind signatures need a 2-prime key of safe primes")
+	errDerivedExponent   = errors.New("simple_rsa: the derived exponent is not invertible")
+)
+
+// PartiallyBlindKey is the key pair of one metadata value. E′ is half as
+// long as N and does not fit the int E of PublicKey. The private half is
+// only set by DerivePartiallyBlindKey.
+type PartiallyBlindKey struct {
+	N, E *big.Int
+
+	d    *big.Int
+	priv *PrivateKey
+}
+
+// Size returns the modulus size in bytes
+func (key *PartiallyBlindKey) Size() int {
+	return (key.N.BitLen() + 7) / 8
+}
+
+// GeneratePartiallyBlindKey generates a 2-prime key of safe primes for
+// partially blind signatures
+func GeneratePartiallyBlindKey(random io.Reader, bits int) (*PrivateKey, error) {
+	return GenerateKeyWithOptions(bits, &KeyGenOptions{Random: random, NPrimes: 2, Prime: PrimeSafe})
+}
+
+// hkdfSHA384 returns n octets of HKDF-SHA384 (RFC 5869) of secret
+func hkdfSHA384(secret, salt, info []byte, n int) []byte {
+	extract := hmac.New(sha512.New384, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha512.New384, extract.Sum(nil))
+
+	var out, t []byte
+	for counter := byte(1); len(out) < n; counter++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		t = expand.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:n]
+}
+
+// DerivePartiallyBlindPublicKey derives the public key of a metadata value:
+// with λ = bits(N) / 2, E′ is the first λ / 8 octets of
+// HKDF-SHA384(salt = N, "key" || metadata || 0x00, "PBRSA"), made odd and
+// below 2^(λ - 2)
+func DerivePartiallyBlindPublicKey(pub *PublicKey, metadata []byte) *PartiallyBlindKey {
+	lambda := pub.N.BitLen() / 2
+	secret := append(append([]byte("key"), metadata...), 0)
+	okm := hkdfSHA384(secret, pub.N.FillBytes(make([]byte, pub.Size())), []byte("PBRSA"), (lambda+128)/8)
+
+	e := new(big.Int).SetBytes(okm[:lambda/8])
+	e.SetBit(e, 0, 1)
+	e.SetBit(e, lambda-1, 0)
+	e.SetBit(e, lambda-2, 0)
+	return &PartiallyBlindKey{N: pub.N, E: e}
+}
+
+// DerivePartiallyBlindKey derives the key pair of a metadata value from a
+// key of safe primes
+func DerivePartiallyBlindKey(priv *PrivateKey, metadata []byte) (*PartiallyBlindKey, error) {
+	phi := safePrimeOrder(priv)
+	if phi == nil {
+		return nil, errPartiallyBlindKey
+	}
+	phi.Lsh(phi, 2)
+	key := DerivePartiallyBlindPublicKey(&priv.PublicKey, metadata)
+	if key.d = new(big.Int).ModInverse(key.E, phi); key.d == nil {
+		return nil, errDerivedExponent
+	}
+	key.priv = priv
+	return key, nil
+}
+
+// encodeMetadata returns "msg" || I2OSP(len(metadata), 4) || metadata || msg
+func encodeMetadata(msg, metadata []byte) []byte {
+	encoded := make([]byte, 7, 7+len(metadata)+len(msg))
+	copy(encoded, "msg")
+	binary.BigEndian.PutUint32(encoded[3:], uint32(len(metadata)))
+	return append(append(encoded, metadata...), msg...)
+}
+
+// PartiallyBlind blinds msg for the key of metadata:
+// blindedMsg = EMSA-PSS-ENCODE(msg, metadata) · r^E′ mod N. The client
+// keeps inv = r^-1 mod N for PartiallyBlindFinalize.
+func PartiallyBlind(random io.Reader, pub *PublicKey, msg, metadata []byte) (blindedMsg []byte, inv *big.Int, err error) {
+	if err = checkPub(pub); err != nil {
+		return nil, nil, err
+	}
+	salt := make([]byte, BlindSHA384PSSRandomized.saltLength())
+	if _, err = io.ReadFull(random, salt); err != nil {
+		return nil, nil, err
+	}
+	var r *big.Int
+	for inv == nil {
+		if r, err = randomBelow(random, pub.N); err != nil {
+			return nil, nil, err
+		}
+		if r.Sign() != 0 {
+			inv = modMultiInverse(r, pub.N)
+		}
+	}
+	blindedMsg, err = partiallyBlind(DerivePartiallyBlindPublicKey(pub, metadata), msg, metadata, salt, r)
+	return blindedMsg, inv, err
+}
+
+// partiallyBlind returns EMSA-PSS-ENCODE(msg, metadata, salt) · r^E′ mod N
+func partiallyBlind(key *PartiallyBlindKey, msg, metadata, salt []byte, r *big.Int) ([]byte, error) {
+	mHash := sha512.Sum384(encodeMetadata(msg, metadata))
+	em, err := emsaPSSEncode(mHash[:], key.N.BitLen()-1, salt, sha512.New384(), mgf1(sha512.New384()))
+	if err != nil {
+		return nil, err
+	}
+	m := new(big.Int).SetBytes(em)
+	if new(big.Int).GCD(nil, nil, m, key.N).Cmp(bigOne) != 0 {
+		return nil, errBlindMessage
+	}
+	z := new(big.Int).Exp(r, key.E, key.N)
+	z.Mul(z, m).Mod(z, key.N)
+	return z.FillBytes(make([]byte, key.Size())), nil
+}
+
+// PartiallyBlindSign signs a blinded message with the key derived for
+// metadata, the signer learns nothing of the message
+func PartiallyBlindSign(random io.Reader, priv *PrivateKey, blindedMsg, metadata []byte) ([]byte, error) {
+	if err := checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	key, err := DerivePartiallyBlindKey(priv, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(blindedMsg) != key.Size() {
+		return nil, errBlindSize
+	}
+	m := new(big.Int).SetBytes(blindedMsg)
+	if m.Cmp(key.N) >= 0 {
+		return nil, errBlindSize
+	}
+	s, err := key.sign(random, m)
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, key.Size())), nil
+}
+
+// sign returns m^D′ mod N. As decrypt does, it blinds m with r^E′ when
+// random is set, uses the constant time CRT of the precomputed key with the
+// exponents D′ mod (p_i - 1), and checks the result against E′.
+func (key *PartiallyBlindKey) sign(random io.Reader, m *big.Int) (*big.Int, error) {
+	priv := key.priv
+	c, rInv := m, (*big.Int)(nil)
+	if random != nil {
+		var r *big.Int
+		for rInv == nil {
+			var err error
+			if r, err = randomBelow(random, key.N); err != nil {
+				return nil, err
+			}
+			if r.Sign() != 0 {
+				rInv = modMultiInverse(r, key.N)
+			}
+		}
+		c = new(big.Int).Exp(r, key.E, key.N)
+		c.Mul(c, m).Mod(c, key.N)
+	}
+
+	var s *big.Int
+	if priv.Precomputed.hasMont(2) {
+		exps := make([]*big.Int, 2)
+		for i, p := range priv.Primes {
+			exps[i] = new(big.Int).Mod(key.d, new(big.Int).Sub(p, bigOne))
+		}
+		exps, ybits, err := blindCRTExponents(random, priv, exps)
+		if err != nil {
+			return nil, err
+		}
+		s = combineCRT(priv, [][]big.Word{expCRT(priv, c, 0, exps[0], ybits[0]), expCRT(priv, c, 1, exps[1], ybits[1])})
+	} else {
+		s = new(big.Int).Exp(c, key.d, key.N)
+	}
+
+	if new(big.Int).Exp(s, key.E, key.N).Cmp(c) != 0 {
+		return nil, errInternal
+	}
+	if rInv != nil {
+		s = mulModN(priv, s, rInv)
+	}
+	return s, nil
+}
+
+// PartiallyBlindFinalize unblinds the blind signature, sig = blindSig · inv
+// mod N, and returns sig if it is a valid signature of msg and metadata
+func PartiallyBlindFinalize(pub *PublicKey, msg, metadata, blindSig []byte, inv *big.Int) ([]byte, error) {
+	if len(blindSig) != pub.Size() {
+		return nil, errBlindSize
+	}
+	s := new(big.Int).SetBytes(blindSig)
+	s.Mul(s, inv).Mod(s, pub.N)
+	sig := s.FillBytes(make([]byte, pub.Size()))
+	if err := PartiallyBlindVerify(pub, msg, metadata, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// PartiallyBlindVerify verifies the RSASSA-PSS signature of msg under the
+// key derived for metadata
+func PartiallyBlindVerify(pub *PublicKey, msg, metadata, sig []byte) error {
+	if err := checkPub(pub); err != nil {
+		return err
+	}
+	key := DerivePartiallyBlindPublicKey(pub, metadata)
+	if len(sig) != key.Size() {
+		return ErrVerification
+	}
+	emBits := key.N.BitLen() - 1
+	emLen := (emBits + 7) / 8
+	m := new(big.Int).Exp(new(big.Int).SetBytes(sig), key.E, key.N)
+	if m.BitLen() > emLen*8 {
+		return ErrVerification
+	}
+	mHash := sha512.Sum384(encodeMetadata(msg, metadata))
+	return emsaPSSVerify(mHash[:], m.FillBytes(make([]byte, emLen)), emBits, BlindSHA384PSSRandomized.saltLength(), sha512.New384(), mgf1(sha512.New384()))
+}
@@ -0,0 +1,95 @@
+package lib_simplersa
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestPartiallyBlindSignatures(t *testing.T) {
+	priv, err := GeneratePartiallyBlindKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg, metadata := []byte("blind me"), []byte("valid until 2026-12-31")
+
+	blinded, inv, err := PartiallyBlind(rand.Reader, pub, msg, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindSig, err := PartiallyBlindSign(rand.Reader, priv, blinded, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := PartiallyBlindFinalize(pub, msg, metadata, blindSig, inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PartiallyBlindVerify(pub, msg, metadata, sig); err != nil {
+		t.Errorf("PartiallyBlindVerify: %s", err)
+	}
+	if PartiallyBlindVerify(pub, msg, []byte("valid until 2027-12-31"), sig) == nil {
+		t.Errorf("the signature verifies under other metadata")
+	}
+	if PartiallyBlindVerify(pub, []byte("another message"), metadata, sig) == nil {
+		t.Errorf("the signature verifies another message")
+	}
+
+	// signed for other metadata than the client blinded for
+	blindSig, err = PartiallyBlindSign(rand.Reader, priv, blinded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PartiallyBlindFinalize(pub, msg, metadata, blindSig, inv); err == nil {
+		t.Errorf("finalized a signature of other metadata")
+	}
+
+	// without the precomputed values and blinding
+	bare := &PrivateKey{PublicKey: priv.PublicKey, D: priv.D, Primes: priv.Primes}
+	blindSig, err = PartiallyBlindSign(nil, bare, blinded, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PartiallyBlindFinalize(pub, msg, metadata, blindSig, inv); err != nil {
+		t.Errorf("PartiallyBlindFinalize without precomputed values: %s", err)
+	}
+}
+
+func TestDerivePartiallyBlindKey(t *testing.T) {
+	priv, err := GeneratePartiallyBlindKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := DerivePartiallyBlindKey(priv, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := DerivePartiallyBlindKey(priv, []byte("b"))
+	if a.E.Cmp(b.E) == 0 {
+		t.Errorf("two metadata values derive the same exponent")
+	}
+	if again := DerivePartiallyBlindPublicKey(&priv.PublicKey, []byte("a")); again.E.Cmp(a.E) != 0 {
+		t.Errorf("the public and private derivations differ")
+	}
+	// E′ is odd, below 2^(λ - 2), and D′ inverts it
+	lambda := priv.N.BitLen() / 2
+	if a.E.Bit(0) != 1 || a.E.BitLen() > lambda-2 {
+		t.Errorf("E′ = %x", a.E)
+	}
+	m, _ := rand.Int(rand.Reader, priv.N)
+	s := new(big.Int).Exp(m, a.d, priv.N)
+	if s.Exp(s, a.E, priv.N).Cmp(m) != 0 {
+		t.Errorf("D′ does not invert E′")
+	}
+
+	// a key of ordinary primes
+	plain, _ := GenerateKey(rand.Reader, 1024)
+	if _, err := DerivePartiallyBlindKey(plain, nil); err != errPartiallyBlindKey {
+		t.Errorf("a key of ordinary primes: got %v, want %v", err, errPartiallyBlindKey)
+	}
+	multi, _ := GenerateMultiPrimeKey(rand.Reader, 3, 1024)
+	if _, err := DerivePartiallyBlindKey(multi, nil); err != errPartiallyBlindKey {
+		t.Errorf("a 3-prime key: got %v, want %v", err, errPartiallyBlindKey)
+	}
+}
@@ -13,8 +13,10 @@ import (
 type PKCS1v15DecryptOptions struct {
 	// SessionKeyLen is the length of the session key that is being
 	// decrypted. If not zero, then a padding error during decryption will
-	// cause a random plaintext of this length to be returned rather than
-	// an error. These alternatives happen in constant time.
+	// cause a synthetic plaintext of this length, derived from the
+	// ciphertext, to be returned rather than an error. If zero, the
+	// plaintext is decrypted with implicit rejection. These alternatives
+	// happen in constant time.
 	SessionKeyLen int
 }
 
@@ -95,37 +97,64 @@ func DecryptPKCS1v15(random io.Reader, priv *PrivateKey, ciphertext []byte) (msg
 	em := bigM.FillBytes(make([]byte, k))
 
 	// 3. EME-PKCS1-v1_5 decoding:
-	//		EM = 0x00 || 0x02 || PS || 0x00 || M.
+	return emePKCS1v15Decode(em)
+}
+
+// emePKCS1v15Decode returns M of EM = 0x00 || 0x02 || PS || 0x00 || M
+func emePKCS1v15Decode(em []byte) (msg []byte, err error) {
+	valid, index := emePKCS1v15Check(em)
+	if valid != 1 {
+		return nil, ErrDecryption
+	}
+	return em[2+index:], nil
+}
+
+// emePKCS1v15Check returns 1 if EM is conforming, 0 otherwise, and the
+// index of M in EM[2:], in constant time
+func emePKCS1v15Check(em []byte) (valid, index int) {
 	firstByteIsZero := subtle.ConstantTimeByteEq(em[0], 0)
 	secondByteIsTwo := subtle.ConstantTimeByteEq(em[1], 2)
 
 	var lookingForIndex int = 1
-	var index int
 	rest := em[2:]
 	for i, val := range rest {
 		valIs0 := subtle.ConstantTimeByteEq(val, 0)
-		index = subtle.ConstantTimeSelect(valIs0, i+1, index)
+		index = subtle.ConstantTimeSelect(lookingForIndex&valIs0, i+1, index)
 		lookingForIndex = subtle.ConstantTimeSelect(valIs0, 0, lookingForIndex)
-		if lookingForIndex == 0 {
-			break
-		}
 	}
 
 	// check whether len(PS) >= 8
 	validPS := subtle.ConstantTimeLessOrEq(8, index-1)
 
 	//fmt.Println("[Debug]", firstByteIsZero, secondByteIsTwo, validPS, lookingForIndex)
-	if firstByteIsZero&secondByteIsTwo&validPS&(^lookingForIndex) != 1 {
-		return nil, ErrDecryption
-	}
-
-	msg = rest[index:]
+	valid = firstByteIsZero & secondByteIsTwo & validPS & (^lookingForIndex & 1)
 	return
 }
 
 func SignPKCS1v15(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest []byte) (sig []byte, err error) {
 
 	// 1. EMSA-PKCS1-v1_5 encoding:
+	emLen := priv.Size()
+	em, err := emsaPKCS1v15Encode(hash, digest, emLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. RSA encryption:
+	//	2.a. m = OS2IP(EM)
+	m := new(big.Int).SetBytes(em)
+	// 	2.b. s = RSASP((n, d), m)
+	bigS, err := decrypt(random, priv, m)
+	if err != nil {
+		return nil, err
+	}
+	// 	2.c. S = I2OSP(s, k)
+	sig = bigS.FillBytes(make([]byte, emLen))
+	return sig, nil
+}
+
+// emsaPKCS1v15Encode returns EM = 0x00 || 0x01 || PS || 0x00 || T of emLen octets
+func emsaPKCS1v15Encode(hash crypto.Hash, digest []byte, emLen int) (em []byte, err error) {
 	// 	1.a. digest = Hash(M).
 	//	1.b. Encode the algorithm ID for the hash function
 	hashLen, prefix, err := getHashInfoPKCS1v15(hash, len(digest))
@@ -133,7 +162,7 @@ func SignPKCS1v15(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest [
 		return nil, err
 	}
 
-	tLen, emLen := len(prefix)+hashLen, priv.Size()
+	tLen := len(prefix) + hashLen
 	// 	1.c. Length checking: mLen <= k - 11
 	if emLen < tLen+11 {
 		return nil, ErrMessageTooLong
@@ -141,7 +170,7 @@ func SignPKCS1v15(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest [
 
 	// 	1.d. Generate PS (emLen - tLen -3 >= 8) 0xff octets
 	// 	1.e. Concatenate: EM = 0x00 || 0x01 || PS || 0x00 || T
-	em := make([]byte, emLen)
+	em = make([]byte, emLen)
 	em[0], em[1] = 0, 1
 	ps := em[2 : emLen-tLen-1]
 	for i := 0; i < len(ps); i++ {
@@ -150,18 +179,7 @@ func SignPKCS1v15(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest [
 	em[emLen-tLen-1] = 0
 	copy(em[emLen-tLen:emLen-hashLen], prefix)
 	copy(em[emLen-hashLen:], digest)
-
-	// 2. RSA encryption:
-	//	2.a. m = OS2IP(EM)
-	m := new(big.Int).SetBytes(em)
-	// 	2.b. s = RSASP((n, d), m)
-	bigS, err := decryptAndCheck(random, priv, m)
-	if err != nil {
-		return nil, err
-	}
-	// 	2.c. S = I2OSP(s, k)
-	sig = bigS.FillBytes(make([]byte, emLen))
-	return sig, nil
+	return em, nil
 }
 
 func VerifyPKCS1v15(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte) error {
@@ -211,14 +229,20 @@ func VerifyPKCS1v15(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte)
 //   }
 // Precompute a prefix of the digest value
 var hashPrefixes = map[crypto.Hash][]byte{
-	crypto.MD5:       {0x30, 0x20, 0x30, 0x0c, 0x06, 0x08, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x02, 0x05, 0x05, 0x00, 0x04, 0x10},
-	crypto.SHA1:      {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
-	crypto.SHA224:    {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
-	crypto.SHA256:    {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
-	crypto.SHA384:    {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
-	crypto.SHA512:    {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
-	crypto.MD5SHA1:   {}, // A special TLS case which doesn't use an ASN1 prefix.
-	crypto.RIPEMD160: {0x30, 0x20, 0x30, 0x08, 0x06, 0x06, 0x28, 0xcf, 0x06, 0x03, 0x00, 0x31, 0x04, 0x14},
+	crypto.MD5:        {0x30, 0x20, 0x30, 0x0c, 0x06, 0x08, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x02, 0x05, 0x05, 0x00, 0x04, 0x10},
+	crypto.SHA1:       {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224:     {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256:     {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384:     {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512:     {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	crypto.SHA512_224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x05, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA512_256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x06, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA3_224:   {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x07, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA3_256:   {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x08, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA3_384:   {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x09, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA3_512:   {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x0a, 0x05, 0x00, 0x04, 0x40},
+	crypto.MD5SHA1:    {}, // A special TLS case which doesn't use an ASN1 prefix.
+	crypto.RIPEMD160:  {0x30, 0x20, 0x30, 0x08, 0x06, 0x06, 0x28, 0xcf, 0x06, 0x03, 0x00, 0x31, 0x04, 0x14},
 }
 
 func getHashInfoPKCS1v15(hash crypto.Hash, inLen int) (hashLen int, prefix []byte, err error) {
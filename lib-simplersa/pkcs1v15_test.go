@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base64"
@@ -205,6 +206,28 @@ func TestSignPKCS1v15(t *testing.T) {
 	}
 }
 
+// the DigestInfo prefixes agree with crypto/rsa
+func TestSignPKCS1v15Hashes(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := &rsa.PublicKey{N: priv.N, E: priv.E}
+	for _, hash := range []crypto.Hash{crypto.SHA512_224, crypto.SHA512_256, crypto.SHA3_224, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512} {
+		h := hash.New()
+		h.Write([]byte("Test.\n"))
+		digest := h.Sum(nil)
+		sig, err := SignPKCS1v15(nil, priv, hash, digest)
+		if err != nil {
+			t.Errorf("%s: %s", hash, err)
+			continue
+		}
+		if err := rsa.VerifyPKCS1v15(std, hash, digest, sig); err != nil {
+			t.Errorf("%s: crypto/rsa: %s", hash, err)
+		}
+	}
+}
+
 func TestVerifyPKCS1v15(t *testing.T) {
 	for i, test := range signPKCS1v15Tests {
 		h := sha1.New()
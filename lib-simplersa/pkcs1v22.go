@@ -52,6 +52,20 @@ func mgf1XOR(out []byte, hash hash.Hash, seed []byte) error {
 }
 
 func EncryptOAEP(hash hash.Hash, random io.Reader, pub *PublicKey, msg []byte, label []byte) (c []byte, err error) {
+	return encryptOAEP(hash, hash, random, pub, msg, label)
+}
+
+// EncryptOAEPWithOptions encrypts msg with RSAES-OAEP, opts picks the hash,
+// the MGF1 hash and the label
+func EncryptOAEPWithOptions(random io.Reader, pub *PublicKey, msg []byte, opts *OAEPOptions) (c []byte, err error) {
+	hash, mgfHash, err := opts.hashes()
+	if err != nil {
+		return nil, err
+	}
+	return encryptOAEP(hash, mgfHash, random, pub, msg, opts.Label)
+}
+
+func encryptOAEP(hash, mgfHash hash.Hash, random io.Reader, pub *PublicKey, msg []byte, label []byte) (c []byte, err error) {
 	if err = checkPub(pub); err != nil {
 		return nil, err
 	}
@@ -67,7 +81,7 @@ func EncryptOAEP(hash hash.Hash, random io.Reader, pub *PublicKey, msg []byte, l
 	}
 
 	// 2. EME-OAEP encoding:
-	em, err := emeOAEPEncode(hash, random, msg, label, k)
+	em, err := emeOAEPEncode(hash, mgfHash, random, msg, label, k)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +99,20 @@ func EncryptOAEP(hash hash.Hash, random io.Reader, pub *PublicKey, msg []byte, l
 }
 
 func DecryptOAEP(hash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext []byte, label []byte) (msg []byte, err error) {
+	return decryptOAEP(hash, hash, random, priv, ciphertext, label)
+}
+
+// DecryptOAEPWithOptions decrypts c with RSAES-OAEP, opts picks the hash,
+// the MGF1 hash and the label
+func DecryptOAEPWithOptions(random io.Reader, priv *PrivateKey, ciphertext []byte, opts *OAEPOptions) (msg []byte, err error) {
+	hash, mgfHash, err := opts.hashes()
+	if err != nil {
+		return nil, err
+	}
+	return decryptOAEP(hash, mgfHash, random, priv, ciphertext, opts.Label)
+}
+
+func decryptOAEP(hash, mgfHash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext []byte, label []byte) (msg []byte, err error) {
 	if err = checkPub(&priv.PublicKey); err != nil {
 		return nil, err
 	}
@@ -111,7 +139,7 @@ func DecryptOAEP(hash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext
 	em := bigM.FillBytes(make([]byte, k))
 
 	// 3. EME-OAEP decoding:
-	msg, err = emeOAEPDecode(hash, em, label)
+	msg, err = emeOAEPDecode(hash, mgfHash, em, label)
 	if err != nil {
 		return nil, err
 	}
@@ -126,8 +154,12 @@ func SignPSS(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest []byte
 	if opts != nil && opts.Hash != 0 {
 		hash = opts.Hash
 	}
+	mgf, err := opts.mgf(hash)
+	if err != nil {
+		return nil, err
+	}
 
-	return signPSSWithSalt(random, priv, hash, digest, opts.saltLength())
+	return signPSSWithSalt(random, priv, hash.New(), mgf, digest, opts.saltLength())
 }
 
 func VerifyPSS(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte, opts *PSSOptions) error {
@@ -138,6 +170,10 @@ func VerifyPSS(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte, opts
 	if opts != nil && opts.Hash != 0 {
 		hash = opts.Hash
 	}
+	mgf, err := opts.mgf(hash)
+	if err != nil {
+		return err
+	}
 
-	return verifyPSSWithSalt(pub, hash, digest, sig, opts.saltLength())
+	return verifyPSSWithSalt(pub, hash.New(), mgf, digest, sig, opts.saltLength())
 }
@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
@@ -229,6 +230,55 @@ var testEncryptOAEPData = []testEncryptOAEPStruct{
 	},
 }
 
+// OAEP with SHA-256 and MGF1-SHA1, the Java and HSM default, interoperates
+// with crypto/rsa both ways
+func TestOAEPMGFHash(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: priv.N, E: priv.E}, D: priv.D, Primes: priv.Primes}
+	std.Precompute()
+	msg, label := []byte("interop"), []byte("label")
+	opts := &OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1, Label: label}
+	stdOpts := &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1, Label: label}
+
+	c, err := EncryptOAEPWithOptions(rand.Reader, &priv.PublicKey, msg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := std.Decrypt(rand.Reader, c, stdOpts); err != nil || !bytes.Equal(m, msg) {
+		t.Errorf("crypto/rsa: %q, %v", m, err)
+	}
+	if _, err := DecryptOAEP(sha256.New(), nil, priv, c, label); err != ErrDecryption {
+		t.Errorf("MGF1-SHA256: got %v, want %v", err, ErrDecryption)
+	}
+
+	// rsa.EncryptOAEPWithOptions of Go 1.26 with test2048Key
+	c = fromHex("5c70a313d563e190cd5414517fe76a0fc30dbbc568f47b8f7ec53bfb9c03317d7b43537754a65243bc920efdb5897ad3f26bc958e984d8e8eac5c01c20921d7b00f15fcc96494c4d2c44fc3e11b91762ac797870bb98a96c0da581c0415bf402a224fc0ecd1524f52a532746e072d773a79383d6850067da461dec3b0e64badd81db490e27c78c8ca581eccc96dd54f0f99ab26ae106b32a15e25faf834ab733826ffd3472a60db3ff28f7812dc8643a0b0b5639c91406f1de96e2b20ece4022d5ff559a04699a511b3f886f3ad269293e07681244d28b62c2df0b8982a52eb2e41530aaf00148faede1cc027480ab339eab28950c8f450ed5aa576615ae02fe")
+	if m, err := test2048Key.Decrypt(rand.Reader, c, opts); err != nil || !bytes.Equal(m, msg) {
+		t.Errorf("Decrypt: %q, %v", m, err)
+	}
+	if m, err := test2048Key.Decrypt(rand.Reader, c, stdOpts); err != nil || !bytes.Equal(m, msg) {
+		t.Errorf("Decrypt with rsa.OAEPOptions: %q, %v", m, err)
+	}
+	if c, err = priv.PublicKey.Encrypt(rand.Reader, msg, stdOpts); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := DecryptOAEPWithOptions(nil, priv, c, opts); err != nil || !bytes.Equal(m, msg) {
+		t.Errorf("PublicKey.Encrypt with rsa.OAEPOptions: %q, %v", m, err)
+	}
+	if _, err := EncryptOAEPWithOptions(rand.Reader, &priv.PublicKey, msg, &OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.MD4}); err == nil {
+		t.Errorf("EncryptOAEPWithOptions accepted an unavailable MGF hash")
+	}
+	if _, err := EncryptOAEPWithOptions(rand.Reader, &priv.PublicKey, msg, nil); err == nil {
+		t.Errorf("EncryptOAEPWithOptions accepted nil options")
+	}
+	if _, err := DecryptOAEPWithOptions(rand.Reader, priv, c, nil); err == nil {
+		t.Errorf("DecryptOAEPWithOptions accepted nil options")
+	}
+}
+
 func ExampleEncryptOAEP() {
 	secretMessage := []byte("send reinforcements, we're going to advance")
 	label := []byte("orders")
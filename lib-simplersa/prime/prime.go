@@ -0,0 +1,429 @@
+// Package prime generates random, safe and strong primes and primes in an
+// arithmetic progression, for RSA moduli and Diffie-Hellman groups. The
+// candidates are sieved by the odd primes below SieveLimit and tested with
+// Miller-Rabin.
+package prime
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	bigZero = big.NewInt(0)
+	bigOne  = big.NewInt(1)
+	bigTwo  = big.NewInt(2)
+)
+
+const (
+	// primeSearchWindow is the number of offsets scanned after a random start
+	primeSearchWindow = uint64(1 << 20)
+	// sieveChunkSize is the number of offsets sieved at a time by a
+	// sequential search
+	sieveChunkSize = uint64(1 << 12)
+)
+
+var (
+	errPrimeSize       = errors.New("simple_rsa: prime size must be at least 2-bit")
+	errSafePrimeSize   = errors.New("simple_rsa: safe primes must be at least 16-bit")
+	errStrongPrimeSize = errors.New("simple_rsa: strong primes must be at least 64-bit")
+	errProgression     = errors.New("simple_rsa: no prime of the given size in the progression")
+)
+
+// Random returns a random prime of exactly bits bits. The candidates after a
+// random start are sieved and the survivors are tested with Miller-Rabin.
+func Random(random io.Reader, bits int) (*big.Int, error) {
+	return RandomParallel(random, bits, 1, sieveChunkSize)
+}
+
+// RandomParallel is Random with the window after the random start split
+// into chunks of chunkSize offsets, searched by a pool of workers goroutines
+func RandomParallel(random io.Reader, bits, workers int, chunkSize uint64) (p *big.Int, err error) {
+	if bits < 2 {
+		return nil, errPrimeSize
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	sz := chunkSize
+	if sz == 0 {
+		sz = sieveChunkSize
+	}
+
+	b := uint(bits % 8)
+	if b == 0 {
+		b = 8
+	}
+	pBytes := make([]byte, (bits+7)/8)
+
+	for {
+		if _, err = io.ReadFull(random, pBytes); err != nil {
+			return nil, err
+		}
+
+		pBytes[0] &= uint8(int(1<<b) - 1)
+
+		// 2/3, set the most significant one bit
+		// 1/3, set the most significant two bits
+		if b >= 2 {
+			if rand.Int()%3 != 0 {
+				pBytes[0] |= 3 << (b - 2)
+			} else {
+				pBytes[0] |= 2 << (b - 2)
+			}
+		} else {
+			pBytes[0] |= 1
+			if rand.Int()%3 != 0 {
+				pBytes[1] |= 0x80 // 1000 0000
+			}
+		}
+
+		// Make sure p is odd
+		pBytes[len(pBytes)-1] |= 0x01
+
+		pp := new(big.Int).SetBytes(pBytes)
+
+		// the residues of pp are computed once, every chunk sieves its
+		// offsets from them
+		sieve := newPrimeSieve(pp, bigTwo)
+
+		// found is read by every worker, ansDelta is only written once
+		// and only read after wg.Wait()
+		var (
+			found    int32
+			ansDelta uint64
+			once     sync.Once
+			wg       sync.WaitGroup
+		)
+		controlCh := make(chan struct{}, workers)
+		goroutineCnt := 0
+
+		for l := uint64(0); l < primeSearchWindow && atomic.LoadInt32(&found) == 0; l += sz {
+			controlCh <- struct{}{}
+			wg.Add(1)
+			goroutineCnt++
+			r := l + sz
+			if r > primeSearchWindow {
+				r = primeSearchWindow
+			}
+			go func(l, r uint64) {
+				defer func() {
+					<-controlCh
+					wg.Done()
+				}()
+				// the even offsets in [l, r) are the indices [⌈l/2⌉, ⌈r/2⌉)
+				sieve.survivors((l+1)/2, (r+1)/2, func(i uint64) bool {
+					if atomic.LoadInt32(&found) != 0 {
+						return false
+					}
+					delta := 2 * i
+					x := new(big.Int).Add(pp, new(big.Int).SetUint64(delta))
+					if x.BitLen() == bits && ProbablyPrime(x, 20) {
+						once.Do(func() {
+							ansDelta = delta
+							atomic.StoreInt32(&found, 1)
+						})
+						return false
+					}
+					return true
+				})
+			}(l, r)
+		}
+		wg.Wait()
+
+		log.Println("ansDelta", ansDelta, "using", goroutineCnt, "grc")
+		if found == 1 {
+			return new(big.Int).Add(pp, new(big.Int).SetUint64(ansDelta)), nil
+		}
+	}
+}
+
+// ProbablyPrime reports whether x is prime, with n rounds of Miller-Rabin
+// plus one with base 2
+func ProbablyPrime(x *big.Int, n int) bool {
+	if n < 0 {
+		panic("negative n for ProbablyPrime")
+	}
+	if x.Cmp(bigZero) <= 0 {
+		return false
+	}
+	if x.Cmp(big.NewInt(2)) == 0 {
+		return true
+	}
+	if new(big.Int).Mod(x, big.NewInt(2)).Cmp(bigZero) == 0 {
+		return false
+	}
+
+	return probablyPrimeMillerRabin(x, n+1, true)
+}
+
+func probablyPrimeMillerRabin(n *big.Int, testTimes int, force2 bool) bool {
+	bigTwo := big.NewInt(2)
+	if nIs2 := n.Cmp(bigTwo); nIs2 <= 0 || n.Bit(0) == 0 {
+		return nIs2 == 0
+	}
+
+	nMinus1 := new(big.Int).Sub(n, bigOne)
+	a, b := new(big.Int).Set(nMinus1), 0
+	for new(big.Int).Mod(a, bigTwo).Cmp(bigZero) == 0 {
+		a.Div(a, bigTwo)
+		b++
+	}
+
+	randMax := new(big.Int).Sub(n, bigTwo)
+	rand := rand.New(rand.NewSource(int64(0)))
+	x := new(big.Int)
+	for i, j := 0, 0; i < testTimes; i++ {
+		if i == testTimes-1 && force2 {
+			x = x.Set(bigTwo)
+		} else {
+			x = x.Rand(rand, randMax).Add(x, bigTwo)
+		}
+		x = x.Exp(x, a, n)
+		if x.Cmp(bigOne) == 0 {
+			continue
+		}
+		for j = 0; j < b; j++ {
+			if x.Cmp(nMinus1) == 0 {
+				break
+			}
+			x = x.Mul(x, x).Mod(x, n)
+		}
+		if j >= b {
+			return false
+		}
+	}
+	return true
+}
+
+// Safe returns a random safe prime p = 2q + 1 of exactly bits bits,
+// q is prime as well. Both p and q are sieved at once, p passes a Fermat
+// test to base 2 before q and p are tested with Miller-Rabin.
+func Safe(random io.Reader, bits int) (*big.Int, error) {
+	if bits < 16 {
+		return nil, errSafePrimeSize
+	}
+	for {
+		q, err := randomOdd(random, bits-1)
+		if err != nil {
+			return nil, err
+		}
+		sieve := newPrimeSieve(q, bigTwo)
+		sieve.safe = true
+
+		safe := func(x *big.Int) *big.Int {
+			return new(big.Int).Add(new(big.Int).Lsh(x, 1), bigOne)
+		}
+		q = sievedSearch(sieve, q, bigTwo, primeSearchWindow/2, bits-1, func(x *big.Int) bool {
+			p := safe(x)
+			return fermatPrime(p) && ProbablyPrime(x, 20) && ProbablyPrime(p, 20)
+		})
+		if q != nil {
+			return safe(q), nil
+		}
+	}
+}
+
+// StrongFactors are the large prime factors that make p a strong prime:
+// R divides p - 1, S divides p + 1 and T divides R - 1
+type StrongFactors struct {
+	R, S, T *big.Int
+}
+
+// Strong returns a random strong prime p of exactly bits bits with
+// Gordon's algorithm:
+//
+//  1. pick random primes s and t of about bits/2 bits
+//  2. find the first prime r = 2it + 1
+//  3. p0 = 2(s^(r-2) mod r)s - 1, so that p0 ≡ 1 mod r and p0 ≡ -1 mod s
+//  4. find the first prime p = p0 + 2jrs of bits bits
+func Strong(random io.Reader, bits int) (p *big.Int, factors *StrongFactors, err error) {
+	if bits < 64 {
+		return nil, nil, errStrongPrimeSize
+	}
+	sBits, tBits := bits/2-8, bits/2-16
+	for {
+		factors = new(StrongFactors)
+		if factors.S, err = Random(random, sBits); err != nil {
+			return nil, nil, err
+		}
+		if factors.T, err = Random(random, tBits); err != nil {
+			return nil, nil, err
+		}
+
+		// r = 2it + 1 for an 8-bit i0 <= i
+		i0, err := randomOdd(random, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		step := new(big.Int).Lsh(factors.T, 1)
+		base := new(big.Int).Mul(step, i0)
+		base.Add(base, bigOne)
+		rBits := base.BitLen()
+		factors.R = sievedSearch(newPrimeSieve(base, step), base, step, primeSearchWindow, rBits, func(x *big.Int) bool {
+			return ProbablyPrime(x, 20)
+		})
+		if factors.R == nil || factors.R.Cmp(factors.S) == 0 {
+			continue
+		}
+
+		// p0 = 2(s^(r-2) mod r)s - 1
+		r, s := factors.R, factors.S
+		p0 := new(big.Int).Exp(s, new(big.Int).Sub(r, bigTwo), r)
+		p0.Mul(p0, s).Lsh(p0, 1).Sub(p0, bigOne)
+
+		// p = p0 + 2jrs with a random j in the lower half of the range
+		// that keeps p at bits bits
+		step = new(big.Int).Mul(r, s)
+		step.Lsh(step, 1)
+		jMin := new(big.Int).Lsh(bigOne, uint(bits-1))
+		jMin.Sub(jMin, p0).Add(jMin, step).Sub(jMin, bigOne).Quo(jMin, step)
+		if jMin.Sign() < 0 {
+			jMin.SetInt64(0)
+		}
+		j, err := randomBelow(random, jMin)
+		if err != nil {
+			return nil, nil, err
+		}
+		j.Add(j, jMin)
+		base = new(big.Int).Mul(step, j)
+		base.Add(base, p0)
+		p = sievedSearch(newPrimeSieve(base, step), base, step, primeSearchWindow, bits, func(x *big.Int) bool {
+			return fermatPrime(x) && ProbablyPrime(x, 20)
+		})
+		if p != nil {
+			return p, factors, nil
+		}
+	}
+}
+
+// InProgression returns a random prime p ≡ a mod m of exactly bits
+// bits, a and m must be coprime
+func InProgression(random io.Reader, bits int, a, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 || bits < 2 || m.BitLen() >= bits {
+		return nil, errProgression
+	}
+	a = new(big.Int).Mod(a, m)
+	if new(big.Int).GCD(nil, nil, a, m).Cmp(bigOne) != 0 {
+		return nil, errProgression
+	}
+
+	// only odd candidates: a step of m if m is even, of 2m otherwise
+	step := new(big.Int).Set(m)
+	if m.Bit(0) == 1 {
+		if a.Bit(0) == 0 {
+			a.Add(a, m)
+		}
+		step.Lsh(step, 1)
+	}
+
+	// p = a + k * step in [2^(bits-1), 2^bits)
+	kMin := new(big.Int).Lsh(bigOne, uint(bits-1))
+	kMin.Sub(kMin, a).Add(kMin, step).Sub(kMin, bigOne).Quo(kMin, step)
+	kMax := new(big.Int).Lsh(bigOne, uint(bits))
+	kMax.Sub(kMax, bigOne).Sub(kMax, a).Quo(kMax, step)
+	if kMin.Sign() < 0 {
+		kMin.SetInt64(0)
+	}
+	if kMin.Cmp(kMax) > 0 {
+		return nil, errProgression
+	}
+	count := new(big.Int).Sub(kMax, kMin)
+	count.Add(count, bigOne)
+
+	if count.Cmp(new(big.Int).SetUint64(primeSearchWindow)) <= 0 {
+		// a short progression is scanned from its start, once
+		base := new(big.Int).Mul(step, kMin)
+		base.Add(base, a)
+		p := sievedSearch(newPrimeSieve(base, step), base, step, count.Uint64(), bits, func(x *big.Int) bool {
+			return ProbablyPrime(x, 20)
+		})
+		if p == nil {
+			return nil, errProgression
+		}
+		return p, nil
+	}
+
+	for {
+		k, err := randomBelow(random, count)
+		if err != nil {
+			return nil, err
+		}
+		base := k.Add(k, kMin).Mul(k, step)
+		base.Add(base, a)
+		p := sievedSearch(newPrimeSieve(base, step), base, step, primeSearchWindow, bits, func(x *big.Int) bool {
+			return ProbablyPrime(x, 20)
+		})
+		if p != nil {
+			return p, nil
+		}
+	}
+}
+
+// sievedSearch returns the first x = base + i * step, i in [0, n), that
+// survives the sieve, has bits bits and is accepted, or nil. The search
+// stops at the first candidate longer than bits bits.
+func sievedSearch(sieve *primeSieve, base, step *big.Int, n uint64, bits int, accept func(x *big.Int) bool) (found *big.Int) {
+	for l := uint64(0); l < n && found == nil; l += sieveChunkSize {
+		r := l + sieveChunkSize
+		if r > n {
+			r = n
+		}
+		sieve.survivors(l, r, func(i uint64) bool {
+			x := new(big.Int).Mul(step, new(big.Int).SetUint64(i))
+			x.Add(x, base)
+			if x.BitLen() > bits {
+				n = 0
+				return false
+			}
+			if x.BitLen() == bits && accept(x) {
+				found = x
+				return false
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// fermatPrime reports whether 2^(x-1) ≡ 1 mod x for an odd x > 2, a cheap
+// test that rules out most composites before Miller-Rabin
+func fermatPrime(x *big.Int) bool {
+	return new(big.Int).Exp(bigTwo, new(big.Int).Sub(x, bigOne), x).Cmp(bigOne) == 0
+}
+
+// randomOdd returns a random odd number of exactly bits bits, bits >= 2
+func randomOdd(random io.Reader, bits int) (*big.Int, error) {
+	buf := make([]byte, (bits+7)/8)
+	if _, err := io.ReadFull(random, buf); err != nil {
+		return nil, err
+	}
+	x := new(big.Int).SetBytes(buf)
+	x.Rsh(x, uint(len(buf)*8-bits))
+	return x.SetBit(x, bits-1, 1).SetBit(x, 0, 1), nil
+}
+
+// randomBelow returns a uniform random number in [0, max), 0 if max <= 0
+func randomBelow(random io.Reader, max *big.Int) (*big.Int, error) {
+	if max.Sign() <= 0 {
+		return new(big.Int), nil
+	}
+	// rejection sampling of max.BitLen() bits
+	bits := max.BitLen()
+	buf := make([]byte, (bits+7)/8)
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, err
+		}
+		x := new(big.Int).SetBytes(buf)
+		x.Rsh(x, uint(len(buf)*8-bits))
+		if x.Cmp(max) < 0 {
+			return x, nil
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package prime
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestSafe(t *testing.T) {
+	if _, err := Safe(rand.Reader, 15); err == nil {
+		t.Errorf("Return no err when random safe prime with bits < 16")
+	}
+
+	sizes := []int{16, 17, 64, 256, 512}
+	if testing.Short() {
+		sizes = sizes[:4]
+	}
+	for _, size := range sizes {
+		p, err := Safe(rand.Reader, size)
+		if err != nil {
+			t.Errorf("%d bits: failed to random a safe prime: %s", size, err)
+			continue
+		}
+		q := new(big.Int).Rsh(p, 1)
+		if p.BitLen() != size || !p.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+			t.Errorf("%d bits: %v is not a %d-bit safe prime", size, p, size)
+		}
+	}
+}
+
+func TestStrong(t *testing.T) {
+	if _, _, err := Strong(rand.Reader, 63); err == nil {
+		t.Errorf("Return no err when random strong prime with bits < 64")
+	}
+
+	sizes := []int{64, 65, 256, 1024}
+	if testing.Short() {
+		sizes = sizes[:3]
+	}
+	for _, size := range sizes {
+		p, f, err := Strong(rand.Reader, size)
+		if err != nil {
+			t.Errorf("%d bits: failed to random a strong prime: %s", size, err)
+			continue
+		}
+		if p.BitLen() != size || !p.ProbablyPrime(20) {
+			t.Errorf("%d bits: %v is not a %d-bit prime", size, p, size)
+		}
+		for _, x := range []*big.Int{f.R, f.S, f.T} {
+			if !x.ProbablyPrime(20) || x.BitLen() < size/2-16 {
+				t.Errorf("%d bits: factor %v is not a large prime", size, x)
+			}
+		}
+		pMinus1, pPlus1, rMinus1 := new(big.Int).Sub(p, bigOne), new(big.Int).Add(p, bigOne), new(big.Int).Sub(f.R, bigOne)
+		m := new(big.Int)
+		if m.Mod(pMinus1, f.R).Sign() != 0 || m.Mod(pPlus1, f.S).Sign() != 0 || m.Mod(rMinus1, f.T).Sign() != 0 {
+			t.Errorf("%d bits: R | p - 1, S | p + 1, T | R - 1 do not hold for %v, %+v", size, p, f)
+		}
+	}
+}
+
+func TestInProgression(t *testing.T) {
+	for _, test := range []struct {
+		bits int
+		a, m int64
+		ok   bool
+	}{
+		{64, 1, 4, true},
+		{64, 3, 4, true},
+		{64, 2, 3, true},
+		{64, -1, 65537, true},
+		{256, 1, 1 << 40, true},
+		{256, 12345, 1<<61 - 1, true},
+		{8, 1, 10, true},
+		{8, 2, 4, false},
+		{8, 0, 1, true},
+		{8, 1, 255, false},
+		{8, 1, 0, false},
+		{6, 9, 24, false},
+		{6, 7, 24, false},
+	} {
+		a, m := big.NewInt(test.a), big.NewInt(test.m)
+		p, err := InProgression(rand.Reader, test.bits, a, m)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("InProgression(%d, %d, %d) = %v, want an error", test.bits, test.a, test.m, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("InProgression(%d, %d, %d): %s", test.bits, test.a, test.m, err)
+			continue
+		}
+		want := new(big.Int).Mod(a, m)
+		if p.BitLen() != test.bits || !p.ProbablyPrime(20) || new(big.Int).Mod(p, m).Cmp(want) != 0 {
+			t.Errorf("InProgression(%d, %d, %d) = %v, not a prime of the progression", test.bits, test.a, test.m, p)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	if _, err := Random(rand.Reader, 1); err == nil {
+		t.Errorf("Return no err when random prime with bits < 2")
+	}
+
+	if _, err := Random(rand.Reader, -100); err == nil {
+		t.Errorf("Return no err when random prime with bits < 2")
+	}
+
+	for _, size := range []int{2, 3, 16, 128} {
+		for _, workers := range []int{1, 3} {
+			p, err := RandomParallel(rand.Reader, size, workers, 10)
+			if err != nil || p.BitLen() != size || !p.ProbablyPrime(20) {
+				t.Errorf("%d bits, %d workers: %v, %v is not a prime", size, workers, p, err)
+			}
+		}
+	}
+}
+
+func TestProbablyPrime(t *testing.T) {
+	isPrimes := map[*big.Int]bool{
+		big.NewInt(0): false,
+		big.NewInt(1): false,
+		big.NewInt(2): true,
+	}
+	var smallPrimes = []int64{
+		3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
+	}
+	for _, sp := range smallPrimes {
+		isPrimes[big.NewInt(sp)] = true
+	}
+
+	bits := []int{64, 128, 512, 1024, 2048}
+	for _, bit := range bits {
+		// prime
+		p, err := rand.Prime(rand.Reader, bit)
+		if err == nil {
+			isPrimes[new(big.Int).Set(p)] = true
+			t.Log("prime: ", p.BitLen())
+		}
+		// maybe not prime
+		num, err := rand.Int(rand.Reader, p)
+		if err == nil {
+			isPrimes[new(big.Int).Set(num)] = num.ProbablyPrime(20)
+			t.Log("number: ", num.BitLen())
+		}
+	}
+
+	t.Log("testcase:", len(isPrimes))
+
+	for val, isP := range isPrimes {
+		if ProbablyPrime(val, 20) != isP {
+			t.Errorf("failed to judge prime: %v is (%v) prime", val, isP)
+		}
+	}
+}
+
+func BenchmarkProbablyPrime(b *testing.B) {
+	b.StopTimer()
+	var primeBits = []int{128, 512, 1024, 2048}
+	var primes []struct {
+		bit int
+		p   *big.Int
+	}
+	for _, bit := range primeBits {
+		p, _ := rand.Prime(rand.Reader, bit)
+		primes = append(primes, struct {
+			bit int
+			p   *big.Int
+		}{bit: bit, p: p})
+	}
+	for _, P := range primes {
+		testName := fmt.Sprintf("P=%d", P.bit)
+		b.Run(testName, func(bs *testing.B) {
+			bs.StartTimer()
+			for i := 0; i < bs.N; i++ {
+				ProbablyPrime(P.p, 20)
+			}
+		})
+	}
+}
+
+func BenchmarkRandomPrime(b *testing.B) {
+	var primeBits = []int{128, 512, 1024, 2048}
+	for _, bit := range primeBits {
+		testName := fmt.Sprintf("P=%d", bit)
+		b.Run(testName, func(bs *testing.B) {
+			bs.StartTimer()
+			for i := 0; i < bs.N; i++ {
+				Random(rand.Reader, bit)
+			}
+		})
+	}
+}
+
+func BenchmarkStdRandomPrime(b *testing.B) {
+	var primeBits = []int{128, 512, 1024, 2048, 3072}
+	for _, bit := range primeBits {
+		testName := fmt.Sprintf("P=%d", bit)
+		b.Run(testName, func(bs *testing.B) {
+			bs.StartTimer()
+			for i := 0; i < bs.N; i++ {
+				rand.Prime(rand.Reader, bit)
+			}
+		})
+	}
+}
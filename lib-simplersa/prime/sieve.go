@@ -0,0 +1,157 @@
+package prime
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// _W is the size of a big.Word in bits
+const _W = bits.UintSize
+
+// SieveLimit bounds the odd primes used to sieve prime candidates,
+// the 3511 odd primes below 2^15
+const SieveLimit = 1 << 15
+
+// sievePrimes are the odd primes below SieveLimit
+var sievePrimes = OddPrimesBelow(SieveLimit)
+
+// OddPrimesBelow returns the odd primes below n with the sieve of Eratosthenes
+func OddPrimesBelow(n int) []uint32 {
+	composite := make([]bool, n)
+	var primes []uint32
+	for i := 3; i < n; i += 2 {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, uint32(i))
+		for j := i * i; j < n; j += 2 * i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
+
+// primeSieve sieves the candidates base + i * step. It holds the residues of
+// base and step modulo sievePrimes, the residues of the candidates of any
+// window are derived from them without another big.Int division.
+type primeSieve struct {
+	baseResidues, stepResidues []uint32
+	// stepInverses are the inverses of the step residues, 0 if p | step
+	stepInverses []uint32
+	// safe also rules out the candidates x with 2x + 1 divisible by a
+	// sieve prime, for safe primes 2x + 1
+	safe bool
+	// small and smallStep are base and step if both are below 2^32, the
+	// candidates then may be sieve primes themselves
+	small, smallStep uint64
+}
+
+func newPrimeSieve(base, step *big.Int) *primeSieve {
+	s := &primeSieve{baseResidues: residues(base), stepResidues: residues(step)}
+	s.stepInverses = make([]uint32, len(sievePrimes))
+	for j, p := range sievePrimes {
+		if s.stepResidues[j] != 0 {
+			s.stepInverses[j] = uint32(modInverseUint(uint64(s.stepResidues[j]), uint64(p)))
+		}
+	}
+	if base.BitLen() <= 32 && step.BitLen() <= 32 {
+		s.small, s.smallStep = base.Uint64(), step.Uint64()
+	}
+	return s
+}
+
+// residues returns x mod p for every sieve prime p
+func residues(x *big.Int) []uint32 {
+	rs := make([]uint32, len(sievePrimes))
+	words := x.Bits()
+	for i, p := range sievePrimes {
+		rs[i] = residue(words, p)
+	}
+	return rs
+}
+
+// Residue returns x mod p for a p below 2^32
+func Residue(x *big.Int, p uint32) uint32 {
+	return residue(x.Bits(), p)
+}
+
+// residue returns x mod p of the words of x
+func residue(words []big.Word, p uint32) uint32 {
+	// shift the words in from the top, 32 bits at a time
+	var r uint64
+	for j := len(words) - 1; j >= 0; j-- {
+		w := uint64(words[j])
+		for k := _W - 32; k >= 0; k -= 32 {
+			r = (r<<32 | (w>>uint(k))&0xffffffff) % uint64(p)
+		}
+	}
+	return uint32(r)
+}
+
+// survivors calls f with every index i in [l, r) such that no sieve prime
+// divides base + i * step (nor 2(base + i * step) + 1 for a safe sieve),
+// until f returns false
+func (s *primeSieve) survivors(l, r uint64, f func(i uint64) bool) {
+	if l >= r {
+		return
+	}
+	composite := make([]bool, r-l)
+	for j, p := range sievePrimes {
+		s.mark(composite, l, j, 0)
+		if s.safe {
+			s.mark(composite, l, j, (uint64(p)-1)/2)
+		}
+	}
+	for i, c := range composite {
+		if !c && !f(l+uint64(i)) {
+			return
+		}
+	}
+}
+
+// mark sets composite[i] for the candidates base + (l + i) * step ≡ a mod
+// the j-th sieve prime p, sparing the candidate that is p itself (or
+// (p - 1) / 2 for a safe sieve, then 2x + 1 = p)
+func (s *primeSieve) mark(composite []bool, l uint64, j int, a uint64) {
+	p, base, step := uint64(sievePrimes[j]), uint64(s.baseResidues[j]), uint64(s.stepResidues[j])
+	spared := a
+	if a == 0 {
+		spared = p
+	}
+	isSpared := func(i uint64) bool {
+		return s.smallStep != 0 && s.small+(l+i)*s.smallStep == spared
+	}
+
+	target := (a + p - (base+l%p*step)%p) % p
+	if step == 0 {
+		// every candidate is ≡ base mod p
+		if target == 0 {
+			for i := range composite {
+				if !isSpared(uint64(i)) {
+					composite[i] = true
+				}
+			}
+		}
+		return
+	}
+
+	// (l + i) * step ≡ a - base mod p
+	for i := target * uint64(s.stepInverses[j]) % p; i < uint64(len(composite)); i += p {
+		if !isSpared(i) {
+			composite[i] = true
+		}
+	}
+}
+
+// modInverseUint returns x^-1 mod p for a prime p that does not divide x
+func modInverseUint(x, p uint64) uint64 {
+	// x^(p - 2) mod p, p < 2^15 keeps the products below 2^64
+	z, e := uint64(1), p-2
+	for x %= p; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			z = z * x % p
+		}
+		x = x * x % p
+	}
+	return z
+}
@@ -0,0 +1,74 @@
+package prime
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestSieve(t *testing.T) {
+	if len(sievePrimes) != 3511 || sievePrimes[0] != 3 || sievePrimes[len(sievePrimes)-1] != 32749 {
+		t.Fatalf("bad sieve primes: %d primes, %d ... %d", len(sievePrimes), sievePrimes[0], sievePrimes[len(sievePrimes)-1])
+	}
+
+	// divisible reports whether a sieve prime other than x divides x
+	divisible := func(x *big.Int) bool {
+		for _, p := range sievePrimes {
+			bigP := big.NewInt(int64(p))
+			if new(big.Int).Mod(x, bigP).Sign() == 0 && x.Cmp(bigP) != 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	large, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 300))
+	large.SetBit(large, 0, 1)
+	var sieveTestCases = []struct {
+		base, step int64
+		safe       bool
+	}{
+		{1, 2, false},
+		{3, 2, false},
+		{32001, 2, false},
+		{0, 2, false},
+		{1, 6, false},
+		{5, 3 * 7 * 11, false},
+		{1, 2, true},
+		{5, 2, true},
+		{-1, 2, false},
+		{-1, 2, true},
+		{-1, 30, true},
+	}
+	for _, test := range sieveTestCases {
+		base := big.NewInt(test.base)
+		if test.base < 0 {
+			base = large
+		}
+		step := big.NewInt(test.step)
+		sieve := newPrimeSieve(base, step)
+		sieve.safe = test.safe
+		for _, window := range [][2]uint64{{0, 500}, {1, 499}, {35000, 35617}} {
+			var got []uint64
+			sieve.survivors(window[0], window[1], func(i uint64) bool {
+				got = append(got, i)
+				return true
+			})
+
+			var want []uint64
+			for i := window[0]; i < window[1]; i++ {
+				x := new(big.Int).Mul(step, new(big.Int).SetUint64(i))
+				x.Add(x, base)
+				safeX := new(big.Int).Lsh(x, 1)
+				if divisible(x) || test.safe && divisible(safeX.Add(safeX, bigOne)) {
+					continue
+				}
+				want = append(want, i)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%v + i * %v, safe %v, window %v: survivors %v, want %v", base, step, test.safe, window, got, want)
+			}
+		}
+	}
+}
@@ -27,6 +27,9 @@ type PSSOptions struct {
 
 	// Hash is the hash function used to generate the message digest.
 	Hash crypto.Hash
+
+	// MGFHash is the hash function of MGF1, Hash when it is zero
+	MGFHash crypto.Hash
 }
 
 func (opts *PSSOptions) saltLength() int {
@@ -40,7 +43,30 @@ func (opts *PSSOptions) HashFunc() crypto.Hash {
 	return opts.Hash
 }
 
-func emsaPSSEncode(mHash []byte, emBits int, salt []byte, hash hash.Hash) (em []byte, err error) {
+// mgf returns MGF1 of opts.MGFHash, or of hash when it is not set, and
+// checks that both hash functions are available
+func (opts *PSSOptions) mgf(hash crypto.Hash) (maskGen, error) {
+	mgfHash := hash
+	if opts != nil && opts.MGFHash != 0 {
+		mgfHash = opts.MGFHash
+	}
+	if !hash.Available() || !mgfHash.Available() {
+		return nil, errors.New("simple_rsa: unsupported hash function")
+	}
+	return mgf1(mgfHash.New()), nil
+}
+
+// maskGen is a mask generation function, it XORs the mask of seed into out
+type maskGen func(out, seed []byte) error
+
+// mgf1 returns MGF1 with hash
+func mgf1(hash hash.Hash) maskGen {
+	return func(out, seed []byte) error {
+		return mgf1XOR(out, hash, seed)
+	}
+}
+
+func emsaPSSEncode(mHash []byte, emBits int, salt []byte, hash hash.Hash, mgf maskGen) (em []byte, err error) {
 	// 1. EMSA-PSS Encoding Operation (randomized)
 	//		Based on Bellare and Rogaway's Probabilistic Signature Scheme (PSS) [RSARABIN][PSS]
 	//
@@ -114,7 +140,7 @@ func emsaPSSEncode(mHash []byte, emBits int, salt []byte, hash hash.Hash) (em []
 	// 9. dbMask = MGF(H, emLen - hLen - 1) = MGF(H, len(db))
 	// 10. maskedDB = db XOR dbMask
 	//				= db XOR MGF(H, len(db))
-	if err = mgf1XOR(db, hash, H); err != nil {
+	if err = mgf(db, H); err != nil {
 		return
 	}
 	// 11. Set the leftmost 8emLen - emBits bits of the leftmost octet in maskedDB to zero.
@@ -126,7 +152,7 @@ func emsaPSSEncode(mHash []byte, emBits int, salt []byte, hash hash.Hash) (em []
 	return em, err
 }
 
-func emsaPSSVerify(mHash, em []byte, emBits, sLen int, hash hash.Hash) error {
+func emsaPSSVerify(mHash, em []byte, emBits, sLen int, hash hash.Hash, mgf maskGen) error {
 	if (emBits+7)/8 != len(em) {
 		return errors.New("simple_rsa: inconsistent length")
 	}
@@ -157,7 +183,7 @@ func emsaPSSVerify(mHash, em []byte, emBits, sLen int, hash hash.Hash) error {
 	// 7.   Let dbMask = MGF(H, emLen - hLen - 1)
 	// 8.   Let db = maskedDB XOR dbMask
 	//			   = maskedDB XOR MGF(H, len(maskedDB))
-	if err := mgf1XOR(db, hash, H); err != nil {
+	if err := mgf(db, H); err != nil {
 		return err
 	}
 
@@ -200,9 +226,29 @@ func emsaPSSVerify(mHash, em []byte, emBits, sLen int, hash hash.Hash) error {
 	return nil
 }
 
-func signPSSWithSalt(random io.Reader, priv *PrivateKey, hash crypto.Hash, digest []byte, saltLength int) (sig []byte, err error) {
+func signPSSWithSalt(random io.Reader, priv *PrivateKey, hash hash.Hash, mgf maskGen, digest []byte, saltLength int) (sig []byte, err error) {
 	// 1. EMSA-PSS encoding:
-	k, emBits := priv.Size(), priv.N.BitLen()-1
+	k := priv.Size()
+	em, err := encodePSSWithSalt(random, priv.N.BitLen()-1, hash, mgf, digest, saltLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. RSA signature:
+	m := new(big.Int).SetBytes(em)
+	bigS, err := decrypt(random, priv, m)
+	if err != nil {
+		return nil, err
+	}
+	sig = bigS.FillBytes(make([]byte, k))
+
+	// 3. Output the signature sig
+	return sig, nil
+}
+
+// encodePSSWithSalt returns EMSA-PSS-ENCODE of digest with a random salt of
+// saltLength octets, or one of the PSSSaltLength constants
+func encodePSSWithSalt(random io.Reader, emBits int, hash hash.Hash, mgf maskGen, digest []byte, saltLength int) (em []byte, err error) {
 	emLen := (emBits + 7) / 8
 	switch saltLength {
 	case PSSSaltLengthAuto:
@@ -217,24 +263,10 @@ func signPSSWithSalt(random io.Reader, priv *PrivateKey, hash crypto.Hash, diges
 		return nil, ErrPSSEncoding
 	}
 
-	em, err := emsaPSSEncode(digest, emBits, salt, hash.New())
-	if err != nil {
-		return nil, err
-	}
-
-	// 2. RSA signature:
-	m := new(big.Int).SetBytes(em)
-	bigS, err := decryptAndCheck(random, priv, m)
-	if err != nil {
-		return nil, err
-	}
-	sig = bigS.FillBytes(make([]byte, k))
-
-	// 3. Output the signature sig
-	return sig, nil
+	return emsaPSSEncode(digest, emBits, salt, hash, mgf)
 }
 
-func verifyPSSWithSalt(pub *PublicKey, hash crypto.Hash, digest []byte, sig []byte, saltLength int) error {
+func verifyPSSWithSalt(pub *PublicKey, hash hash.Hash, mgf maskGen, digest []byte, sig []byte, saltLength int) error {
 	// 1. EMSA-PSS encoding:
 	k, emBits := pub.Size(), pub.N.BitLen()-1 // modBits - 1
 	emLen := (emBits + 7) / 8
@@ -257,5 +289,5 @@ func verifyPSSWithSalt(pub *PublicKey, hash crypto.Hash, digest []byte, sig []by
 	}
 	em := m.FillBytes(make([]byte, emLen))
 	// 3. EMSA-PSS verification:
-	return emsaPSSVerify(digest, em, emBits, saltLength, hash.New())
+	return emsaPSSVerify(digest, em, emBits, saltLength, hash, mgf)
 }
@@ -49,7 +49,7 @@ func TestEMSAPSS(t *testing.T) {
 	hash.Write(msg)
 	hashed := hash.Sum(nil)
 
-	encoded, err := emsaPSSEncode(hashed, 1023, salt, sha1.New())
+	encoded, err := emsaPSSEncode(hashed, 1023, salt, sha1.New(), mgf1(sha1.New()))
 	if err != nil {
 		t.Errorf("Error from emsaPSSEncode: %s\n", err)
 	}
@@ -57,7 +57,7 @@ func TestEMSAPSS(t *testing.T) {
 		t.Errorf("Bad encoding. got %x, want %x", encoded, expected)
 	}
 
-	if err = emsaPSSVerify(hashed, encoded, 1023, len(salt), sha1.New()); err != nil {
+	if err = emsaPSSVerify(hashed, encoded, 1023, len(salt), sha1.New(), mgf1(sha1.New())); err != nil {
 		t.Errorf("Bad verification: %s", err)
 	}
 }
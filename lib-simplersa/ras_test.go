@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
+	"io"
 	"math/big"
+	"sync"
 	"testing"
 )
 
@@ -68,6 +70,139 @@ func TestNPrimeKeyGeneration(t *testing.T) {
 	}
 }
 
+func TestKeyGenerationWithOptions(t *testing.T) {
+	size := 1024
+	if testing.Short() {
+		size = 256
+	}
+	var testCases = []*KeyGenOptions{
+		nil,
+		{Random: rand.Reader, Parallel: true},
+		{Random: rand.Reader, Parallel: true, Workers: 3, ChunkSize: 50, NPrimes: 3},
+		{Random: rand.Reader, E: 3, PrimeSizes: []int{size / 4, size - size/4}},
+	}
+	// Generations with different options run concurrently, run with -race.
+	var wg sync.WaitGroup
+	for i, opts := range testCases {
+		wg.Add(1)
+		go func(i int, opts *KeyGenOptions) {
+			defer wg.Done()
+			priv, err := GenerateKeyWithOptions(size, opts)
+			if err != nil {
+				t.Errorf("#%d: failed to generate key: %s", i, err)
+				return
+			}
+			if bits := priv.N.BitLen(); bits != size {
+				t.Errorf("#%d: key too short (%d vs %d)", i, bits, size)
+			}
+			testKeyBasics(t, priv)
+		}(i, opts)
+	}
+	wg.Wait()
+
+	var badOptions = []*KeyGenOptions{
+		{NPrimes: 1},
+		{E: 2},
+		{PrimeSizes: []int{size}},
+		{PrimeSizes: []int{size / 2, size / 2, 1}},
+	}
+	for i, opts := range badOptions {
+		if _, err := GenerateKeyWithOptions(size, opts); err == nil {
+			t.Errorf("#%d: no error for bad options %+v", i, opts)
+		}
+	}
+}
+
+func TestKeyGenerationPublicExponent(t *testing.T) {
+	size := 768
+	if testing.Short() {
+		size = 256
+	}
+	for _, e := range []int{3, 17, 65537, RandomPublicExponent} {
+		priv, err := GenerateKeyWithOptions(size, &KeyGenOptions{Random: rand.Reader, E: e})
+		if err != nil {
+			t.Errorf("e=%d: failed to generate key: %s", e, err)
+			continue
+		}
+		if e != RandomPublicExponent && priv.E != e {
+			t.Errorf("e=%d: got public exponent %d", e, priv.E)
+		}
+		if e == RandomPublicExponent && (priv.E < 1<<16+1 || priv.E%2 == 0) {
+			t.Errorf("bad random public exponent %d", priv.E)
+		}
+		for _, prime := range priv.Primes {
+			pMinus1 := new(big.Int).Sub(prime, bigOne)
+			if exGcd(big.NewInt(int64(priv.E)), pMinus1, nil, nil).Cmp(bigOne) != 0 {
+				t.Errorf("e=%d: gcd(e, p-1) != 1 for p = %v", priv.E, prime)
+			}
+		}
+		testKeyBasics(t, priv)
+	}
+}
+
+func TestKeyGenerationUnbalanced(t *testing.T) {
+	size := 1024
+	if testing.Short() {
+		size = 256
+	}
+	sizes := [][]int{
+		{size / 4, size - size/4},
+		{size / 8, size / 8, size - size/4},
+	}
+	for _, primeSizes := range sizes {
+		priv, err := GenerateKeyWithOptions(size, &KeyGenOptions{Random: rand.Reader, PrimeSizes: primeSizes})
+		if err != nil {
+			t.Errorf("%v: failed to generate key: %s", primeSizes, err)
+			continue
+		}
+		if bits := priv.N.BitLen(); bits != size {
+			t.Errorf("%v: key too short (%d vs %d)", primeSizes, bits, size)
+		}
+		for i, prime := range priv.Primes {
+			if prime.BitLen() != primeSizes[i] {
+				t.Errorf("%v: prime #%d has %d bits", primeSizes, i, prime.BitLen())
+			}
+		}
+		testKeyBasics(t, priv)
+	}
+}
+
+func TestCheckPublicExponent(t *testing.T) {
+	var testCases = []struct {
+		e   int
+		err error
+	}{
+		{1, errPublicExponentSmall},
+		{4, errPublicExponentEven},
+		{65536, errPublicExponentEven},
+		{1 << 31, errPublicExponentLarge},
+		{3, nil},
+		{65537, nil},
+	}
+	for _, test := range testCases {
+		pub := &PublicKey{N: big.NewInt(3233), E: test.e}
+		if err := checkPub(pub); err != test.err {
+			t.Errorf("e=%d: got %v, want %v", test.e, err, test.err)
+		}
+		if test.err == nil {
+			continue
+		}
+		if _, err := GenerateKeyWithOptions(256, &KeyGenOptions{E: test.e}); err != test.err {
+			t.Errorf("GenerateKeyWithOptions(e=%d): got %v, want %v", test.e, err, test.err)
+		}
+	}
+
+	// 3 | 61-1, there is no D for e = 3
+	priv := &PrivateKey{
+		PublicKey: PublicKey{N: big.NewInt(61 * 53), E: 3},
+		D:         big.NewInt(1),
+		Primes:    []*big.Int{big.NewInt(61), big.NewInt(53)},
+	}
+	if err := priv.Validate(); err != errPublicExponentPrime {
+		t.Errorf("Validate() got %v, want %v", err, errPublicExponentPrime)
+	}
+}
+
 func TestImpossibleKeyGeneration(t *testing.T) {
 	// This test ensures that trying to generate toy RSA keys doesn't enter
 	// an infinite loop.
@@ -127,6 +262,133 @@ func testKeyBasics(t *testing.T, priv *PrivateKey) {
 	}
 }
 
+func TestBlindExponent(t *testing.T) {
+	d, order := big.NewInt(12345), big.NewInt(100003)
+	if got, _ := blindExponent(nil, d, order); got != d {
+		t.Errorf("blindExponent without random = %v, want %v", got, d)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		got, err := blindExponent(rand.Reader, d, order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if new(big.Int).Mod(got, order).Cmp(d) != 0 || got.BitLen() > order.BitLen()+expBlindBits {
+			t.Errorf("blindExponent = %v, not d + k * order with a %d-bit k", got, expBlindBits)
+		}
+		seen[got.String()] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("blindExponent returned the same exponent every time")
+	}
+}
+
+func TestDecryptFault(t *testing.T) {
+	defer func() { crtFault = nil }()
+	hashed := sha256.Sum256([]byte("testing"))
+
+	for _, nprimes := range []int{2, 3} {
+		priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := big.NewInt(42)
+		c := encrypt(&priv.PublicKey, m)
+		ciphertext, err := EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte("testing"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// flip a bit of the result modulo the faulty prime
+		for faulty := 0; faulty < nprimes; faulty++ {
+			crtFault = func(i int, mi []big.Word) {
+				if i == faulty {
+					mi[0] ^= 1
+				}
+			}
+			for _, random := range []io.Reader{nil, rand.Reader} {
+				if got, err := decrypt(random, priv, c); got != nil || err != errInternal {
+					t.Errorf("%d primes, fault in m%d: decrypt = %v, %v, want nil, %v", nprimes, faulty+1, got, err, errInternal)
+				}
+			}
+			if sig, err := SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hashed[:]); sig != nil || err == nil {
+				t.Errorf("%d primes, fault in m%d: SignPKCS1v15 released a faulty signature", nprimes, faulty+1)
+			}
+			if sig, err := SignPSS(rand.Reader, priv, crypto.SHA256, hashed[:], nil); sig != nil || err == nil {
+				t.Errorf("%d primes, fault in m%d: SignPSS released a faulty signature", nprimes, faulty+1)
+			}
+			if msg, err := DecryptPKCS1v15(rand.Reader, priv, ciphertext); msg != nil || err == nil {
+				t.Errorf("%d primes, fault in m%d: DecryptPKCS1v15 released %x", nprimes, faulty+1, msg)
+			}
+		}
+
+		crtFault = nil
+		if got, err := decrypt(rand.Reader, priv, c); err != nil || got.Cmp(m) != 0 {
+			t.Errorf("%d primes: decrypt without fault = %v, %v, want %v", nprimes, got, err, m)
+		}
+	}
+}
+
+func TestParallelCRT(t *testing.T) {
+	bits := 2560
+	if testing.Short() {
+		bits = 1024
+	}
+	for _, nprimes := range []int{2, 3, 5} {
+		priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parallel := *priv
+		parallel.ParallelCRT = true
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c, _ := rand.Int(rand.Reader, priv.N)
+				want, _ := decrypt(nil, priv, c)
+				for _, random := range []io.Reader{nil, rand.Reader} {
+					if got, err := decrypt(random, &parallel, c); err != nil || got.Cmp(want) != 0 {
+						t.Errorf("%d primes: parallel decrypt = %v, %v, want %v", nprimes, got, err, want)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestGenerateKeyPrimeKind(t *testing.T) {
+	for _, test := range []struct {
+		kind  PrimeKind
+		bits  int
+		check func(p *big.Int) bool
+	}{
+		{PrimeSafe, 256, func(p *big.Int) bool { return new(big.Int).Rsh(p, 1).ProbablyPrime(20) }},
+		{PrimeStrong, 512, func(p *big.Int) bool { return true }},
+	} {
+		priv, err := GenerateKeyWithOptions(test.bits, &KeyGenOptions{Prime: test.kind})
+		if err != nil {
+			t.Errorf("kind %d: %s", test.kind, err)
+			continue
+		}
+		if err = priv.Validate(); err != nil {
+			t.Errorf("kind %d: %s", test.kind, err)
+		}
+		for _, p := range priv.Primes {
+			if !test.check(p) {
+				t.Errorf("kind %d: %v is not of the kind", test.kind, p)
+			}
+		}
+	}
+
+	if _, err := GenerateKeyWithOptions(96, &KeyGenOptions{Prime: PrimeStrong}); err == nil {
+		t.Errorf("Return no err when generating a key of 48-bit strong primes")
+	}
+}
+
 func fromBase10(base10 string) *big.Int {
 	i, ok := new(big.Int).SetString(base10, 10)
 	if !ok {
@@ -207,12 +469,12 @@ func Benchmark4096GenerateKey(b *testing.B) {
 		//{4096, 7},
 		//{4096, 8},
 	}
-	ParaCalc = true
 	for _, test := range bitsNPrimes {
 		testName := fmt.Sprintf("S=%d/%d", test.bits, test.n)
+		opts := &KeyGenOptions{Random: rand.Reader, Parallel: true, NPrimes: test.n}
 		b.Run(testName, func(b1 *testing.B) {
 			for i := 0; i < b1.N; i++ {
-				GenerateMultiPrimeKey(rand.Reader, test.n, test.bits)
+				GenerateKeyWithOptions(test.bits, opts)
 			}
 		})
 	}
@@ -370,3 +632,35 @@ func Benchmark3PrimeRSA2048Decrypt(b *testing.B) {
 		decrypt(nil, priv, c)
 	}
 }
+
+func benchmarkMultiPrimeDecrypt(b *testing.B, nprimes, bits int) {
+	priv, err := GenerateMultiPrimeKey(rand.Reader, nprimes, bits)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, _ := rand.Int(rand.Reader, priv.N)
+	for _, parallel := range []bool{false, true} {
+		priv.ParallelCRT = parallel
+		name := "Sequential"
+		if parallel {
+			name = "Parallel"
+		}
+		b.Run(name, func(bs *testing.B) {
+			for i := 0; i < bs.N; i++ {
+				decrypt(nil, priv, c)
+			}
+		})
+	}
+}
+
+func Benchmark3PrimeRSA2048DecryptParallel(b *testing.B) {
+	benchmarkMultiPrimeDecrypt(b, 3, 2048)
+}
+
+func Benchmark4PrimeRSA4096Decrypt(b *testing.B) {
+	benchmarkMultiPrimeDecrypt(b, 4, 4096)
+}
+
+func Benchmark5PrimeRSA4096Decrypt(b *testing.B) {
+	benchmarkMultiPrimeDecrypt(b, 5, 4096)
+}
@@ -0,0 +1,135 @@
+package lib_simplersa
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// Implicit rejection of EME-PKCS1-v1_5 (draft-irtf-cfrg-rsa-guidance): a
+// ciphertext with bad padding decrypts to a synthetic message derived from
+// the ciphertext under a key derived from D, so the decrypter answers the
+// same way to every ciphertext and is no padding oracle. The same ciphertext
+// always gets the same synthetic message, so retrying it shows nothing
+// either.
+
+// implicitRejectionTries is the number of 16-bit length candidates
+const implicitRejectionTries = 128
+
+// implicitRejectionKDK returns KDK = HMAC-SHA256(SHA256(I2OSP(D, k)), C)
+func implicitRejectionKDK(priv *PrivateKey, ciphertext []byte) []byte {
+	dHash := sha256.Sum256(priv.D.FillBytes(make([]byte, priv.Size())))
+	mac := hmac.New(sha256.New, dHash[:])
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
+
+// implicitRejectionPRF returns bits / 8 octets of HMAC-SHA256(KDK,
+// I2OSP(i, 2) || label || I2OSP(bits, 2)) for i = 0, 1, …
+func implicitRejectionPRF(kdk []byte, label string, bits int) []byte {
+	out := make([]byte, 0, bits/8+sha256.Size)
+	mac := hmac.New(sha256.New, kdk)
+	var i, length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(bits))
+	for n := uint16(0); len(out) < bits/8; n++ {
+		binary.BigEndian.PutUint16(i[:], n)
+		mac.Reset()
+		mac.Write(i[:])
+		mac.Write([]byte(label))
+		mac.Write(length[:])
+		out = mac.Sum(out)
+	}
+	return out[:bits/8]
+}
+
+// syntheticMessage returns the k-octet synthetic block of a ciphertext and
+// the length of the synthetic message at its end, the last of the length
+// candidates below k - 10 masked to the bits of k - 10
+func syntheticMessage(priv *PrivateKey, ciphertext []byte) (synthetic []byte, length int) {
+	k := priv.Size()
+	kdk := implicitRejectionKDK(priv, ciphertext)
+	candidates := implicitRejectionPRF(kdk, "length", implicitRejectionTries*16)
+	synthetic = implicitRejectionPRF(kdk, "message", 8*k)
+
+	maxSepOffset := k - 2 - 8
+	mask := 1<<bits.Len(uint(maxSepOffset-1)) - 1
+	for i := 0; i < len(candidates); i += 2 {
+		candidate := int(binary.BigEndian.Uint16(candidates[i:])) & mask
+		length = subtle.ConstantTimeSelect(ctLess(candidate, maxSepOffset), candidate, length)
+	}
+	return synthetic, length
+}
+
+// ctLess returns 1 if x < y and 0 otherwise, for 0 <= x, y < 2^31
+func ctLess(x, y int) int {
+	return int((uint32(x) - uint32(y)) >> 31)
+}
+
+// decryptPKCS1v15Block returns EM of a ciphertext of the right length
+func decryptPKCS1v15Block(random io.Reader, priv *PrivateKey, ciphertext []byte) (em []byte, err error) {
+	if err = checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	k := priv.Size()
+	if len(ciphertext) != k || k < 11 {
+		return nil, ErrDecryption
+	}
+	m, err := decrypt(random, priv, new(big.Int).SetBytes(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return m.FillBytes(make([]byte, k)), nil
+}
+
+// DecryptPKCS1v15ImplicitRejection decrypts like DecryptPKCS1v15, but a
+// ciphertext with bad padding decrypts to its synthetic message instead of
+// an error. Errors are only returned for a ciphertext of the wrong length,
+// callers must authenticate the message by other means.
+func DecryptPKCS1v15ImplicitRejection(random io.Reader, priv *PrivateKey, ciphertext []byte) (msg []byte, err error) {
+	em, err := decryptPKCS1v15Block(random, priv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return implicitRejectionDecode(priv, ciphertext, em), nil
+}
+
+// implicitRejectionDecode returns M of EM, or the synthetic message of the
+// ciphertext if EM is badly padded, in constant time
+func implicitRejectionDecode(priv *PrivateKey, ciphertext, em []byte) []byte {
+	k := len(em)
+	valid, index := emePKCS1v15Check(em)
+	synthetic, length := syntheticMessage(priv, ciphertext)
+
+	// the real message is em[2 + index:], the synthetic one ends synthetic
+	subtle.ConstantTimeCopy(valid, synthetic, em)
+	length = subtle.ConstantTimeSelect(valid, k-2-index, length)
+	return synthetic[k-length:]
+}
+
+// DecryptPKCS1v15SessionKey decrypts a session key of len(key) octets into
+// key. When the padding is bad or the message has another length, key is
+// set to octets of the synthetic message of the ciphertext instead, in
+// constant time, so a protocol using the key fails later the same way for
+// every bad ciphertext. An error is only returned when the ciphertext has
+// the wrong length or key is too long for the modulus.
+func DecryptPKCS1v15SessionKey(random io.Reader, priv *PrivateKey, ciphertext []byte, key []byte) error {
+	if priv.Size()-(len(key)+3+8) < 0 {
+		return ErrDecryption
+	}
+	em, err := decryptPKCS1v15Block(random, priv, ciphertext)
+	if err != nil {
+		return err
+	}
+	k := len(em)
+	valid, index := emePKCS1v15Check(em)
+	valid &= subtle.ConstantTimeEq(int32(k-2-index), int32(len(key)))
+	synthetic, _ := syntheticMessage(priv, ciphertext)
+
+	copy(key, synthetic[k-len(key):])
+	subtle.ConstantTimeCopy(valid, key, em[k-len(key):])
+	return nil
+}
@@ -0,0 +1,103 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestImplicitRejection(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := priv.Size()
+	msg := []byte("attack at dawn")
+	c, _ := EncryptPKCS1v15(rand.Reader, &priv.PublicKey, msg)
+	if got, err := DecryptPKCS1v15ImplicitRejection(rand.Reader, priv, c); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("a valid ciphertext decrypts to %q, %v, want %q", got, err, msg)
+	}
+
+	// random ciphertexts, the synthetic messages stay the same per
+	// ciphertext and their lengths spread over [0, k - 10)
+	lengths := make(map[int]bool)
+	for i := 0; i < 64; i++ {
+		x, _ := rand.Int(rand.Reader, priv.N)
+		c := x.FillBytes(make([]byte, k))
+		if _, err := DecryptPKCS1v15(nil, priv, c); err == nil {
+			continue
+		}
+		got, err := DecryptPKCS1v15ImplicitRejection(nil, priv, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := DecryptPKCS1v15ImplicitRejection(rand.Reader, priv, c)
+		if !bytes.Equal(got, again) {
+			t.Errorf("the synthetic message of %x changed", c)
+		}
+		if len(got) >= k-10 {
+			t.Errorf("a synthetic message of %d octets", len(got))
+		}
+		lengths[len(got)] = true
+	}
+	if len(lengths) < 32 {
+		t.Errorf("only %d distinct synthetic lengths", len(lengths))
+	}
+
+	// the synthetic message depends on the key
+	other, _ := GenerateKey(rand.Reader, 1024)
+	x, _ := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 1000))
+	c = x.FillBytes(make([]byte, k))
+	a, _ := DecryptPKCS1v15ImplicitRejection(nil, priv, c)
+	b, _ := DecryptPKCS1v15ImplicitRejection(nil, other, c)
+	if bytes.Equal(a, b) && len(a) > 0 {
+		t.Errorf("two keys give the same synthetic message")
+	}
+
+	if _, err := DecryptPKCS1v15ImplicitRejection(nil, priv, c[1:]); err != ErrDecryption {
+		t.Errorf("a short ciphertext: got %v, want %v", err, ErrDecryption)
+	}
+}
+
+func TestDecryptPKCS1v15SessionKey(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 16)
+	rand.Read(key)
+	c, _ := EncryptPKCS1v15(rand.Reader, &priv.PublicKey, key)
+
+	got := make([]byte, 16)
+	if err := DecryptPKCS1v15SessionKey(rand.Reader, priv, c, got); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("DecryptPKCS1v15SessionKey = %x, %v, want %x", got, err, key)
+	}
+	// a key of another length gets synthetic octets
+	long, again := make([]byte, 24), make([]byte, 24)
+	if err := DecryptPKCS1v15SessionKey(rand.Reader, priv, c, long); err != nil {
+		t.Fatal(err)
+	}
+	DecryptPKCS1v15SessionKey(nil, priv, c, again)
+	if bytes.HasSuffix(long, key) || !bytes.Equal(long, again) {
+		t.Errorf("a 24-byte key of a 16-byte message = %x, then %x", long, again)
+	}
+	if err := DecryptPKCS1v15SessionKey(nil, priv, c, make([]byte, priv.Size()-10)); err != ErrDecryption {
+		t.Errorf("a key too long for N: got %v, want %v", err, ErrDecryption)
+	}
+
+	// crypto.Decrypter
+	for _, test := range []struct {
+		opts *PKCS1v15DecryptOptions
+		want []byte
+	}{
+		{&PKCS1v15DecryptOptions{}, key},
+		{&PKCS1v15DecryptOptions{SessionKeyLen: 16}, key},
+		{&PKCS1v15DecryptOptions{SessionKeyLen: 24}, long},
+	} {
+		got, err := priv.Decrypt(rand.Reader, c, test.opts)
+		if err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("Decrypt(%+v) = %x, %v, want %x", *test.opts, got, err, test.want)
+		}
+	}
+}
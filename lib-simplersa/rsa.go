@@ -4,10 +4,15 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/binary"
 	"errors"
 	"io"
 	"math"
 	"math/big"
+	"runtime"
+	"sync"
+
+	"simple-rsa/lib-simplersa/prime"
 )
 
 var (
@@ -16,6 +21,10 @@ var (
 	ErrEncryptOption         = errors.New("simple_rsa: encryption option error")
 	ErrDecryption            = errors.New("simple_rsa: decryption error")
 	ErrVerification          = errors.New("simple_rsa: verification error")
+
+	// errInternal is returned instead of a private-key result that fails
+	// the check against the public exponent, e.g. after a fault in the CRT
+	errInternal = errors.New("simple_rsa: internal error")
 )
 
 type PublicKey struct {
@@ -27,21 +36,48 @@ var (
 	errPublicModulus       = errors.New("simple_rsa: missing public modulus")
 	errPublicExponentSmall = errors.New("simple_rsa: public exponent too small")
 	errPublicExponentLarge = errors.New("simple_rsa: public exponent too large")
+	errPublicExponentEven  = errors.New("simple_rsa: public exponent must be odd")
+	errPublicExponentPrime = errors.New("simple_rsa: public exponent not coprime to p-1")
 )
 
 func checkPub(pub *PublicKey) error {
 	if pub.N == nil {
 		return errPublicModulus
 	}
-	if pub.E < 2 {
+	return checkPublicExponent(pub.E)
+}
+
+// checkPublicExponent rejects exponents that can not be inverted mod φ(N)
+// or do not fit the int range supported by PublicKey
+func checkPublicExponent(e int) error {
+	if e < 2 {
 		return errPublicExponentSmall
 	}
-	if pub.E > (1<<31)-1 {
+	if e > (1<<31)-1 {
 		return errPublicExponentLarge
 	}
+	// φ(N) is even, so an even e never has an inverse
+	if e%2 == 0 {
+		return errPublicExponentEven
+	}
 	return nil
 }
 
+// montgomery returns a new Montgomery context of pub.N for the constant
+// time exponentiations with a secret exponent
+func (pub *PublicKey) montgomery() *montContext {
+	return newMontContext(pub.N)
+}
+
+// montgomery returns the Montgomery context of priv.N cached by Precompute,
+// or a new one if there is none or N has been changed since
+func (priv *PrivateKey) montgomery() *montContext {
+	if ctx := priv.Precomputed.nMont; ctx != nil && ctx.m.Cmp(priv.N) == 0 {
+		return ctx
+	}
+	return priv.PublicKey.montgomery()
+}
+
 // Size returns pub.N size in bytes
 func (pub *PublicKey) Size() int {
 	return (pub.N.BitLen() + 7) / 8
@@ -61,7 +97,9 @@ func (pub *PublicKey) Encrypt(random io.Reader, plaintext []byte, opts crypto.De
 	}
 	switch opts := opts.(type) {
 	case *rsa.OAEPOptions:
-		return EncryptOAEP(opts.Hash.New(), random, pub, plaintext, opts.Label)
+		return EncryptOAEPWithOptions(random, pub, plaintext, &OAEPOptions{Hash: opts.Hash, Label: opts.Label, MGFHash: opts.MGFHash})
+	case *OAEPOptions:
+		return EncryptOAEPWithOptions(random, pub, plaintext, opts)
 	default:
 		return nil, ErrEncryptOption
 	}
@@ -73,8 +111,16 @@ type PrivateKey struct {
 	Primes []*big.Int // N = \prod Primes, has >= 2 elements
 
 	Precomputed PrecomputedValues // speed up private operations
+
+	// ParallelCRT computes the exponentiations modulo the primes of at
+	// least parallelCRTMinBits bits concurrently, one goroutine per prime.
+	ParallelCRT bool
 }
 
+// parallelCRTMinBits is the smallest prime whose exponentiation is worth
+// a goroutine of its own
+const parallelCRTMinBits = 512
+
 func (priv *PrivateKey) Public() crypto.PublicKey {
 	return &priv.PublicKey
 }
@@ -109,7 +155,18 @@ func (priv *PrivateKey) Decrypt(random io.Reader, ciphertext []byte, opts crypto
 	}
 	switch opts := opts.(type) {
 	case *OAEPOptions:
-		return DecryptOAEP(opts.Hash.New(), random, priv, ciphertext, opts.Label)
+		return DecryptOAEPWithOptions(random, priv, ciphertext, opts)
+	case *rsa.OAEPOptions:
+		return DecryptOAEPWithOptions(random, priv, ciphertext, &OAEPOptions{Hash: opts.Hash, Label: opts.Label, MGFHash: opts.MGFHash})
+	case *PKCS1v15DecryptOptions:
+		if l := opts.SessionKeyLen; l > 0 {
+			plaintext = make([]byte, l)
+			if err := DecryptPKCS1v15SessionKey(random, priv, ciphertext, plaintext); err != nil {
+				return nil, err
+			}
+			return plaintext, nil
+		}
+		return DecryptPKCS1v15ImplicitRejection(random, priv, ciphertext)
 	default:
 		return nil, errors.New("simple_rsa: invalid options for Decrypt")
 	}
@@ -132,12 +189,15 @@ func (priv *PrivateKey) Validate() error {
 		return errors.New("simple_rsa: invalid modulus")
 	}
 
-	// Check de ≡ 1 mod p-1
-	de := new(big.Int).SetInt64(int64(priv.E))
-	de.Mul(de, priv.D)
+	// Check gcd(e, p-1) = 1 and de ≡ 1 mod p-1
+	e := new(big.Int).SetInt64(int64(priv.E))
+	de := new(big.Int).Mul(e, priv.D)
 	remainder := new(big.Int)
 	for _, prime := range priv.Primes {
 		pminus1 := new(big.Int).Sub(prime, bigOne)
+		if exGcd(e, pminus1, nil, nil).Cmp(bigOne) != 0 {
+			return errPublicExponentPrime
+		}
 		remainder.Mod(de, pminus1)
 		if remainder.Cmp(bigOne) != 0 {
 			return errors.New("simple-rsa: invalid exponents")
@@ -159,6 +219,11 @@ func (priv *PrivateKey) Precompute() {
 	precomputed.Dq.Mod(priv.D, precomputed.Dq)
 
 	precomputed.Qinv = modMultiInverse(q, p)
+	precomputed.nMont = newMontContext(priv.N)
+	precomputed.pMont, precomputed.qMont = newMontContext(p), newMontContext(q)
+	if precomputed.pMont != nil && precomputed.Qinv != nil {
+		precomputed.qInvMont = precomputed.pMont.toMont(precomputed.Qinv)
+	}
 
 	precomputed.CRTValues = make([]CRTValue, len(priv.Primes)-2)
 	r := new(big.Int).Mul(p, q)
@@ -170,6 +235,9 @@ func (priv *PrivateKey) Precompute() {
 
 		value.R = new(big.Int).Set(r)
 		value.T = modMultiInverse(value.R, prime)
+		if value.mont = newMontContext(prime); value.mont != nil && value.T != nil {
+			value.tMont = value.mont.toMont(value.T)
+		}
 
 		r.Mul(r, prime)
 	}
@@ -181,30 +249,197 @@ type (
 		Qinv   *big.Int // Q^-1 mod P
 
 		CRTValues []CRTValue // more than 2 elements
+
+		nMont        *montContext // Montgomery context of N
+		pMont, qMont *montContext // Montgomery contexts of P and Q
+		qInvMont     []big.Word   // Qinv in Montgomery form mod P
 	}
 	CRTValue struct {
 		DExp *big.Int // D mod (p-1)
 		T    *big.Int // R * T ≡ 1 mod Primes[i + 2]
 		R    *big.Int // R_i = Primes[0]*...*Primes[i+1]
+
+		mont  *montContext // Montgomery context of Primes[i + 2]
+		tMont []big.Word   // T in Montgomery form mod Primes[i + 2]
 	}
 )
 
+// PrimeKind selects the kind of the primes of a key
+type PrimeKind int
+
+const (
+	// PrimeRandom are uniformly chosen random primes
+	PrimeRandom PrimeKind = iota
+	// PrimeSafe are safe primes p = 2q + 1 with a prime q
+	PrimeSafe
+	// PrimeStrong are strong primes from Gordon's algorithm
+	PrimeStrong
+)
+
+// KeyGenOptions configures a single key generation, so that concurrent
+// generations in one process never share state. The zero value generates a
+// 2-prime key with E = 65537 from crypto/rand using a sequential prime search.
+type KeyGenOptions struct {
+	// Parallel searches primes larger than 512 bits with a goroutine pool.
+	Parallel bool
+	// Workers is the size of the goroutine pool, defaults to runtime.NumCPU().
+	Workers int
+	// ChunkSize is the number of candidates handed to a worker at a time.
+	ChunkSize uint64
+
+	// Random is the source of entropy, defaults to crypto/rand.Reader.
+	Random io.Reader
+
+	// E is the public exponent, defaults to 65537. RandomPublicExponent
+	// draws a random odd exponent in [2^16+1, 2^31-1] from Random.
+	E int
+	// NPrimes is the number of primes, defaults to 2.
+	NPrimes int
+	// PrimeSizes fixes the bit length of every prime, it overrides NPrimes
+	// and must sum up to the requested key size.
+	PrimeSizes []int
+	// Prime is the kind of the primes, defaults to PrimeRandom. Safe
+	// primes must be at least 16-bit and strong primes at least 64-bit.
+	Prime PrimeKind
+}
+
+const (
+	defaultPublicExponent = 65537
+	// RandomPublicExponent asks for a random large odd public exponent
+	RandomPublicExponent = -1
+)
+
+var errKeyGenPrimeSizes = errors.New("simple_rsa: prime sizes must be at least 2-bit and sum up to the key size")
+
+func (opts *KeyGenOptions) random() io.Reader {
+	if opts.Random == nil {
+		return rand.Reader
+	}
+	return opts.Random
+}
+
+func (opts *KeyGenOptions) workers() int {
+	if opts.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return opts.Workers
+}
+
+func (opts *KeyGenOptions) chunkSize() uint64 {
+	if opts.ChunkSize == 0 {
+		return defaultChunkSize
+	}
+	return opts.ChunkSize
+}
+
+func (opts *KeyGenOptions) publicExponent() (int, error) {
+	switch opts.E {
+	case 0:
+		return defaultPublicExponent, nil
+	case RandomPublicExponent:
+		// e in [2^16 + 1, 2^31 - 1], odd
+		var buf [4]byte
+		if _, err := io.ReadFull(opts.random(), buf[:]); err != nil {
+			return 0, err
+		}
+		e := int(binary.BigEndian.Uint32(buf[:])%(1<<31-1<<16-1)) + 1<<16 + 1
+		return e | 1, nil
+	}
+	if err := checkPublicExponent(opts.E); err != nil {
+		return 0, err
+	}
+	return opts.E, nil
+}
+
+// prime returns a bits-bit prime of the kind asked for by opts
+func (opts *KeyGenOptions) prime(bits int) (*big.Int, error) {
+	switch opts.Prime {
+	case PrimeSafe:
+		return prime.Safe(opts.random(), bits)
+	case PrimeStrong:
+		p, _, err := prime.Strong(opts.random(), bits)
+		return p, err
+	}
+	return opts.randomPrime(bits)
+}
+
+// primeSizes returns the bit length of every prime of a bits-bit key
+func (opts *KeyGenOptions) primeSizes(bits int) ([]int, error) {
+	if opts.PrimeSizes != nil {
+		if len(opts.PrimeSizes) < 2 {
+			return nil, ErrGenerateMultiPrimeKey
+		}
+		sum := 0
+		for _, size := range opts.PrimeSizes {
+			if size < 2 {
+				return nil, errKeyGenPrimeSizes
+			}
+			sum += size
+		}
+		if sum != bits {
+			return nil, errKeyGenPrimeSizes
+		}
+		return opts.PrimeSizes, nil
+	}
+
+	nprimes := opts.NPrimes
+	if nprimes == 0 {
+		nprimes = 2
+	}
+	if nprimes < 2 {
+		return nil, ErrGenerateMultiPrimeKey
+	}
+
+	todo := bits
+	if nprimes >= 7 {
+		todo += (nprimes - 2) / 5
+	}
+	sizes := make([]int, nprimes)
+	for i := range sizes {
+		sizes[i] = todo / (nprimes - i)
+		todo -= sizes[i]
+	}
+	return sizes, nil
+}
+
 func GenerateKey(random io.Reader, bits int) (*PrivateKey, error) {
 	return GenerateMultiPrimeKey(random, 2, bits)
 }
 
 func GenerateMultiPrimeKey(random io.Reader, nprimes, bits int) (priv *PrivateKey, err error) {
-	// util.MaybeReadByte(random)
-
-	priv = new(PrivateKey)
-	priv.E = 65537 // 3
-
 	if nprimes < 2 {
 		return nil, ErrGenerateMultiPrimeKey
 	}
+	return GenerateKeyWithOptions(bits, &KeyGenOptions{Random: random, NPrimes: nprimes})
+}
+
+// GenerateKeyWithOptions generates a bits-bit key as configured by opts,
+// a nil opts is the same as the zero KeyGenOptions.
+func GenerateKeyWithOptions(bits int, opts *KeyGenOptions) (priv *PrivateKey, err error) {
+	if opts == nil {
+		opts = &KeyGenOptions{}
+	}
+
+	sizes, err := opts.primeSizes(bits)
+	if err != nil {
+		return nil, err
+	}
+	nprimes := len(sizes)
+
+	priv = new(PrivateKey)
+	if priv.E, err = opts.publicExponent(); err != nil {
+		return nil, err
+	}
+	e := big.NewInt(int64(priv.E))
 
 	if bits < 64 {
-		primeMaxVal := float64(uint64(1) << uint(bits/nprimes))
+		minSize := sizes[0]
+		for _, size := range sizes {
+			if size < minSize {
+				minSize = size
+			}
+		}
+		primeMaxVal := float64(uint64(1) << uint(minSize))
 		numPrimeLessMaxVal := primeMaxVal / (math.Log(primeMaxVal) - 1)
 		// Generated primes start with 11 (in binary)
 		numPrimeLessMaxVal /= 4
@@ -215,18 +450,18 @@ func GenerateMultiPrimeKey(random io.Reader, nprimes, bits int) (priv *PrivateKe
 	}
 
 	primes := make([]*big.Int, nprimes)
+	pMinus1 := new(big.Int)
 	for {
-		todo := bits
-
-		if nprimes >= 7 {
-			todo += (nprimes - 2) / 5
-		}
 		for i := 0; i < nprimes; i++ {
 			unique, prime := false, new(big.Int)
 			for !unique {
-				if prime, err = randomPrime(random, todo/(nprimes-i)); err != nil {
+				if prime, err = opts.prime(sizes[i]); err != nil {
 					return
 				}
+				// e must be invertible mod p-1, otherwise D does not exist
+				if exGcd(e, pMinus1.Sub(prime, bigOne), nil, nil).Cmp(bigOne) != 0 {
+					continue
+				}
 				unique = true
 				for j := 0; j < i; j++ {
 					if prime.Cmp(primes[j]) == 0 {
@@ -236,12 +471,10 @@ func GenerateMultiPrimeKey(random io.Reader, nprimes, bits int) (priv *PrivateKe
 				}
 			}
 			primes[i] = prime
-			todo -= primes[i].BitLen()
 		}
 
 		n := new(big.Int).Set(bigOne)
 		phiN := new(big.Int).Set(bigOne)
-		pMinus1 := new(big.Int)
 		for _, prime := range primes {
 			n.Mul(n, prime)
 			phiN.Mul(phiN, pMinus1.Sub(prime, bigOne))
@@ -251,7 +484,6 @@ func GenerateMultiPrimeKey(random io.Reader, nprimes, bits int) (priv *PrivateKe
 		}
 
 		priv.D = new(big.Int)
-		e := big.NewInt(int64(priv.E))
 		if D := modMultiInverse(e, phiN); D != nil {
 			priv.D = D
 			priv.Primes = primes
@@ -267,13 +499,16 @@ func GenerateMultiPrimeKey(random io.Reader, nprimes, bits int) (priv *PrivateKe
 // c = RSAEP((n, e), m)
 func encrypt(pub *PublicKey, m *big.Int) (c *big.Int) {
 	e := big.NewInt(int64(pub.E))
-	//return new(big.Int).Exp(m, e, pub.N)
-	return Pow(m, e, pub.N)
+	return new(big.Int).Exp(m, e, pub.N)
 }
 
 // m = RSADP ((n, d), c).
+//
+// With a random source both the base and the private exponents are blinded.
+// Every result is checked against the public exponent before it is
+// released, a faulty CRT half would otherwise leak a factor of N.
 func decrypt(random io.Reader, priv *PrivateKey, c *big.Int) (m *big.Int, err error) {
-	if c.Cmp(priv.N) > 0 || priv.N.Sign() == 0 {
+	if c.Cmp(priv.N) >= 0 || priv.N.Sign() == 0 {
 		return nil, ErrDecryption
 	}
 
@@ -285,20 +520,94 @@ func decrypt(random io.Reader, priv *PrivateKey, c *big.Int) (m *big.Int, err er
 		}
 	}
 
-	if priv.Precomputed.Dq == nil {
-		m = new(big.Int).Exp(c, priv.D, priv.N)
+	if priv.Precomputed.Dq == nil || !priv.Precomputed.hasMont(len(priv.Primes)) {
+		m, err = expPrivate(random, priv, c)
 	} else {
-		m = speedupExp(priv, c)
+		m, err = speedupExp(random, priv, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypt(&priv.PublicKey, m).Cmp(c) != 0 {
+		return nil, errInternal
 	}
 
 	if rInv != nil {
-		m.Mul(m, rInv)
-		m.Mod(m, priv.N)
+		m = mulModN(priv, m, rInv)
 	}
 
 	return m, nil
 }
 
+// expBlindBits is the size of the random multiplier k of an exponent
+// blinded as d + k * order
+const expBlindBits = 64
+
+// blindExponent returns d + k * order for a random k of expBlindBits bits,
+// or d itself if random is nil
+func blindExponent(random io.Reader, d, order *big.Int) (*big.Int, error) {
+	if random == nil {
+		return d, nil
+	}
+	var buf [expBlindBits / 8]byte
+	if _, err := io.ReadFull(random, buf[:]); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(buf[:])
+	return k.Mul(k, order).Add(k, d), nil
+}
+
+// hasMont reports whether Precompute has set up the Montgomery contexts
+// of all the nprimes primes
+func (precomputed *PrecomputedValues) hasMont(nprimes int) bool {
+	if precomputed.qInvMont == nil || len(precomputed.CRTValues) != nprimes-2 {
+		return false
+	}
+	for i := range precomputed.CRTValues {
+		if precomputed.CRTValues[i].tMont == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// expPrivate returns c^D mod N in constant time, without the CRT.
+// E * D - 1 is a multiple of the order of the group, D is blinded with it.
+func expPrivate(random io.Reader, priv *PrivateKey, c *big.Int) (*big.Int, error) {
+	order := new(big.Int).Mul(big.NewInt(int64(priv.E)), priv.D)
+	d, err := blindExponent(random, priv.D, order.Sub(order, bigOne))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := priv.montgomery()
+	if ctx == nil {
+		return new(big.Int).Exp(c, d, priv.N), nil
+	}
+	dBits := priv.N.BitLen()
+	if priv.D.BitLen() > dBits {
+		dBits = priv.D.BitLen()
+	}
+	if random != nil {
+		dBits = order.BitLen() + expBlindBits + 1
+	}
+	return new(big.Int).SetBits(ctx.ctExp(c.Bits(), d, dBits)), nil
+}
+
+// mulModN returns x * y mod N in constant time for x, y < N
+func mulModN(priv *PrivateKey, x, y *big.Int) *big.Int {
+	ctx := priv.montgomery()
+	if ctx == nil {
+		z := new(big.Int).Mul(x, y)
+		return z.Mod(z, priv.N)
+	}
+	// x * (y * R) / R = x * y
+	z := padWords(x.Bits(), len(ctx.n))
+	ctx.mul(z, z, ctx.toMont(y), ctx.scratch())
+	return new(big.Int).SetBits(z)
+}
+
 func randomMulCiphertext(random io.Reader, priv *PrivateKey, c *big.Int) (newC *big.Int, rInv *big.Int, err error) {
 	// c = m^e, newC = m^e * r^e
 	// newC^d = (m^r * r^e)^d mod n = m^rd * r^ed mod n = m * r
@@ -316,57 +625,121 @@ func randomMulCiphertext(random io.Reader, priv *PrivateKey, c *big.Int) (newC *
 			break
 		}
 	}
-	bigE := big.NewInt(int64(priv.E))
-	rPowE := new(big.Int).Exp(r, bigE, priv.N)
+	rPowE := encrypt(&priv.PublicKey, r)
 	newC = new(big.Int).Mul(c, rPowE)
 	newC.Mod(newC, priv.N)
 	return
 }
 
-func speedupExp(priv *PrivateKey, c *big.Int) *big.Int {
+// crtExponents returns D mod (p_i - 1) for every prime, each blinded with a
+// random multiple of p_i - 1 if random is not nil, and the number of bits
+// the exponentiation with it must process
+func (priv *PrivateKey) crtExponents(random io.Reader) (exps []*big.Int, ybits []int, err error) {
 	precomputed := &priv.Precomputed
-	p, q := priv.Primes[0], priv.Primes[1]
-	m1 := new(big.Int).Mod(c, p)
-	m1.Exp(m1, precomputed.Dp, p)
+	exps = []*big.Int{precomputed.Dp, precomputed.Dq}
+	for i := range precomputed.CRTValues {
+		exps = append(exps, precomputed.CRTValues[i].DExp)
+	}
+	return blindCRTExponents(random, priv, exps)
+}
 
-	m2 := new(big.Int).Mod(c, q)
-	m2.Exp(m2, precomputed.Dq, q)
+// blindCRTExponents blinds exps[i], an exponent mod the i-th prime, with a
+// random multiple of p_i - 1 if random is not nil
+func blindCRTExponents(random io.Reader, priv *PrivateKey, exps []*big.Int) (blinded []*big.Int, ybits []int, err error) {
+	blinded, ybits = make([]*big.Int, len(exps)), make([]int, len(exps))
+	for i, d := range exps {
+		prime := priv.Primes[i]
+		if blinded[i], err = blindExponent(random, d, new(big.Int).Sub(prime, bigOne)); err != nil {
+			return nil, nil, err
+		}
+		ybits[i] = prime.BitLen()
+		if random != nil {
+			ybits[i] += expBlindBits
+		}
+	}
+	return
+}
 
-	// h = (m1 - m2) * Qinv % p
-	h := new(big.Int).Mul(m1.Sub(m1, m2), precomputed.Qinv)
-	h.Mod(h, p)
-	if h.Sign() < 0 {
-		h.Add(h, p)
+// montCRT returns the Montgomery context of the i-th prime
+func (precomputed *PrecomputedValues) montCRT(i int) *montContext {
+	switch i {
+	case 0:
+		return precomputed.pMont
+	case 1:
+		return precomputed.qMont
 	}
+	return precomputed.CRTValues[i-2].mont
+}
+
+// crtFault is set only by the tests. It is called on the result of every
+// CRT exponentiation and may corrupt it to simulate a fault attack.
+var crtFault func(i int, mi []big.Word)
+
+// expCRT returns c^d mod the i-th prime, processing d as ybits bits
+func expCRT(priv *PrivateKey, c *big.Int, i int, d *big.Int, ybits int) []big.Word {
+	cw := padWords(c.Bits(), len(priv.N.Bits()))
+	mi := priv.Precomputed.montCRT(i).ctExp(cw, d, ybits)
+	if crtFault != nil {
+		crtFault(i, mi)
+	}
+	return mi
+}
+
+// combineCRT returns the m < N with m ≡ ms[i] mod the i-th prime. It uses
+// Garner's recombination on words of fixed length in constant time.
+func combineCRT(priv *PrivateKey, ms [][]big.Word) *big.Int {
+	precomputed := &priv.Precomputed
+	pMont, qMont := precomputed.pMont, precomputed.qMont
+	m1, m2 := ms[0], ms[1]
+
+	// h = (m1 - m2) * Qinv % p
+	h := pMont.ctReduce(m2)
+	pMont.ctSubMod(h, m1, h)
+	pMont.mul(h, h, precomputed.qInvMont, pMont.scratch())
+
 	// m = m2 + q * h
-	m := new(big.Int).Add(m2, h.Mul(h, q))
+	m := ctMulWords(qMont.n, h)
+	ctAddWords(m, m, padWords(m2, len(m)))
 
 	for i, values := range precomputed.CRTValues {
-		prime := priv.Primes[2+i]
-		mi := new(big.Int).Mod(c, prime)
-		mi.Exp(mi, values.DExp, prime)
-
 		// h = (m_i - m) * t_i % p_i
-		h.Mod(h.Mul(mi.Sub(mi, m), values.T), prime)
-		if h.Sign() < 0 {
-			h.Add(h, prime)
-		}
+		h = values.mont.ctReduce(m)
+		values.mont.ctSubMod(h, ms[2+i], h)
+		values.mont.mul(h, h, values.tMont, values.mont.scratch())
 
 		// m = m + R * h
-		m.Add(m, h.Mul(values.R, h))
+		rh := ctMulWords(values.R.Bits(), h)
+		m = padWords(m, len(rh))
+		ctAddWords(m, m, rh)
 	}
 
-	return m
+	return new(big.Int).SetBits(m)
 }
 
-func decryptAndCheck(random io.Reader, priv *PrivateKey, c *big.Int) (m *big.Int, err error) {
-	if m, err = decrypt(random, priv, c); err != nil {
+// speedupExp returns c^D mod N with the CRT. Every step works on words of
+// fixed length in constant time: the exponentiations, the reductions of
+// c and of the partial results, and the Garner recombination.
+// The exponent modulo p_i is blinded with a random multiple of p_i - 1.
+// With ParallelCRT the exponentiations run concurrently before Garner's
+// recombination.
+func speedupExp(random io.Reader, priv *PrivateKey, c *big.Int) (*big.Int, error) {
+	exps, ybits, err := priv.crtExponents(random)
+	if err != nil {
 		return nil, err
 	}
-
-	check := encrypt(&priv.PublicKey, m)
-	if c.Cmp(check) != 0 {
-		return nil, errors.New("simple_rsa: internal error")
-	}
-	return
+	ms := make([][]big.Word, len(exps))
+	var wg sync.WaitGroup
+	for i := range exps {
+		if !priv.ParallelCRT || priv.Primes[i].BitLen() < parallelCRTMinBits {
+			ms[i] = expCRT(priv, c, i, exps[i], ybits[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ms[i] = expCRT(priv, c, i, exps[i], ybits[i])
+		}(i)
+	}
+	wg.Wait()
+	return combineCRT(priv, ms), nil
 }
@@ -0,0 +1,124 @@
+package lib_simplersa
+
+import (
+	"crypto/sha3"
+	"errors"
+	"io"
+)
+
+// RSASSA-PSS with SHAKE of RFC 8702, section 3.2: the XOF is both the
+// message hash, 256 bits for SHAKE128 and 512 bits for SHAKE256, and the
+// mask generation function, MGF(H, maskLen) = SHAKE(H, 8maskLen). The salt
+// is as long as the hash and the trailer is 0xBC.
+//
+// Importing crypto/sha3 also registers the SHA3 hash functions, so they
+// are available to the PKCS #1 schemes.
+
+var errSHAKE = errors.New("simple_rsa: unknown SHAKE function")
+
+// SHAKE selects an extendable-output function of RFC 8702
+type SHAKE int
+
+const (
+	// SHAKE128 is id-RSASSA-PSS-SHAKE128, a 32 octet digest
+	SHAKE128 SHAKE = iota + 1
+	// SHAKE256 is id-RSASSA-PSS-SHAKE256, a 64 octet digest
+	SHAKE256
+)
+
+func (x SHAKE) String() string {
+	switch x {
+	case SHAKE128:
+		return "SHAKE128"
+	case SHAKE256:
+		return "SHAKE256"
+	}
+	return "unknown SHAKE function"
+}
+
+// Size returns the digest and salt length of x, 0 for an unknown function
+func (x SHAKE) Size() int {
+	switch x {
+	case SHAKE128:
+		return 32
+	case SHAKE256:
+		return 64
+	}
+	return 0
+}
+
+func (x SHAKE) new() *sha3.SHAKE {
+	if x == SHAKE128 {
+		return sha3.NewSHAKE128()
+	}
+	return sha3.NewSHAKE256()
+}
+
+// Sum returns the digest of msg to sign with SignPSSWithSHAKE
+func (x SHAKE) Sum(msg []byte) []byte {
+	if x.Size() == 0 {
+		return nil
+	}
+	h := x.new()
+	h.Write(msg)
+	digest := make([]byte, x.Size())
+	h.Read(digest)
+	return digest
+}
+
+// shakeHash is x with a fixed output length as a hash.Hash, the H of
+// EMSA-PSS
+type shakeHash struct {
+	*sha3.SHAKE
+	x SHAKE
+}
+
+func (h shakeHash) Size() int {
+	return h.x.Size()
+}
+
+// Sum reads from a copy of the state, so h can be written further
+func (h shakeHash) Sum(b []byte) []byte {
+	state, _ := h.MarshalBinary()
+	c := h.x.new()
+	c.UnmarshalBinary(state)
+	out := make([]byte, h.x.Size())
+	c.Read(out)
+	return append(b, out...)
+}
+
+// shakeMask returns SHAKE as the mask generation function
+func shakeMask(x SHAKE) maskGen {
+	return func(out, seed []byte) error {
+		h := x.new()
+		h.Write(seed)
+		mask := make([]byte, len(out))
+		h.Read(mask)
+		for i := range out {
+			out[i] ^= mask[i]
+		}
+		return nil
+	}
+}
+
+// SignPSSWithSHAKE signs digest, the Sum of x, with RSASSA-PSS of RFC 8702
+func SignPSSWithSHAKE(random io.Reader, priv *PrivateKey, x SHAKE, digest []byte) (sig []byte, err error) {
+	if err = checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	if x.Size() == 0 {
+		return nil, errSHAKE
+	}
+	return signPSSWithSalt(random, priv, shakeHash{x.new(), x}, shakeMask(x), digest, x.Size())
+}
+
+// VerifyPSSWithSHAKE verifies an RSASSA-PSS signature of RFC 8702
+func VerifyPSSWithSHAKE(pub *PublicKey, x SHAKE, digest []byte, sig []byte) error {
+	if err := checkPub(pub); err != nil {
+		return err
+	}
+	if x.Size() == 0 {
+		return errSHAKE
+	}
+	return verifyPSSWithSalt(pub, shakeHash{x.new(), x}, shakeMask(x), digest, sig, x.Size())
+}
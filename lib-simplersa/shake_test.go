@@ -0,0 +1,99 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+// EMSA-PSS of RFC 8702 for "abc" and the salt 00 01 02..., computed with
+// hashlib of Python
+var shakePSSTests = []struct {
+	x          SHAKE
+	emBits     int
+	digest, em string
+}{
+	{
+		SHAKE128, 1023,
+		"5881092dd818bf5cf8a3ddb793fbcba74097d5c526a6d35f97b83351940f2cc8",
+		"18908b83e79af2b6e73883d8f735d60edeb50c0e7654c1dd6c4235730a7b9b915e5ebccd36c66d87cda3a54500fdcc8f69174f366497d25610f0fbb438bb53b71833501348dd2e054c9d9114790f734b4dddd2789cefd3d5739eadb41cd21983e46fae0e4ac7c8511c3612bdcb8d6c0d27f67040221bc3886431766899fee9bc",
+	},
+	{
+		SHAKE256, 1535,
+		"483366601360a8771c6863080cc4114d8db44530f8f1e1ee4f94ea37e78b5739d5a15bef186a5386c75744c0527e1faa9f8726e462a12a4feb06bd8801e751e4",
+		"1131d4b9a301265f7e81976adc0f549950af6da6ce4c290958283ce3db5be7fcc5981c3d6eb40ea4851006182f5a47d5fbced38b3938f093dd498d442969903be183e8c9bdacc9e0f7665d0c149ffed77c77538d4d0ca46f2393f7b2f1fa4fbd988e05b3185ef7086f7882aa3e8baa4d30d2d34238302a89dc461c1d65cc48a0a4f1eff67e851961efb36b76e7b68e49e6c76e054280e2ff111fb6ca4524cc93aab736b32217f52df51fd26770bdcb88c8fd453431ad376d86eb9c6ec7bac1bc",
+	},
+}
+
+func TestEMSAPSSSHAKE(t *testing.T) {
+	for _, test := range shakePSSTests {
+		digest := test.x.Sum([]byte("abc"))
+		if !bytes.Equal(digest, fromHex(test.digest)) {
+			t.Errorf("%s: digest %x", test.x, digest)
+		}
+		salt := make([]byte, test.x.Size())
+		for i := range salt {
+			salt[i] = byte(i)
+		}
+		em, err := emsaPSSEncode(digest, test.emBits, salt, shakeHash{test.x.new(), test.x}, shakeMask(test.x))
+		if err != nil || !bytes.Equal(em, fromHex(test.em)) {
+			t.Errorf("%s: EM = %x, %v", test.x, em, err)
+		}
+	}
+}
+
+func TestSignPSSWithSHAKE(t *testing.T) {
+	// SHAKE256 needs emLen >= 2 * 64 + 2
+	priv, err := GenerateKey(rand.Reader, 1536)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	for _, x := range []SHAKE{SHAKE128, SHAKE256} {
+		digest := x.Sum([]byte("extendable"))
+		sig, err := SignPSSWithSHAKE(rand.Reader, priv, x, digest)
+		if err != nil {
+			t.Fatalf("%s: %s", x, err)
+		}
+		if err := VerifyPSSWithSHAKE(pub, x, digest, sig); err != nil {
+			t.Errorf("%s: %s", x, err)
+		}
+		digest[0] ^= 1
+		if err := VerifyPSSWithSHAKE(pub, x, digest, sig); err != ErrVerification {
+			t.Errorf("%s: another digest: got %v, want %v", x, err, ErrVerification)
+		}
+	}
+	digest := SHAKE128.Sum([]byte("extendable"))
+	sig, _ := SignPSSWithSHAKE(rand.Reader, priv, SHAKE128, digest)
+	if err := VerifyPSSWithSHAKE(pub, SHAKE256, SHAKE256.Sum([]byte("extendable")), sig); err != ErrVerification {
+		t.Errorf("SHAKE128 signature as SHAKE256: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := SignPSSWithSHAKE(rand.Reader, priv, SHAKE(3), digest); err != errSHAKE {
+		t.Errorf("an unknown XOF: got %v, want %v", err, errSHAKE)
+	}
+}
+
+func TestPSSMGFHash(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	digest := sha256.Sum256([]byte("mask"))
+	opts := &PSSOptions{SaltLength: PSSSaltLengthEqualsHash, MGFHash: crypto.SHA1}
+	sig, err := SignPSS(rand.Reader, priv, crypto.SHA256, digest[:], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPSS(pub, crypto.SHA256, digest[:], sig, opts); err != nil {
+		t.Errorf("MGF1-SHA1: %s", err)
+	}
+	if err := VerifyPSS(pub, crypto.SHA256, digest[:], sig, &PSSOptions{SaltLength: PSSSaltLengthEqualsHash}); err != ErrVerification {
+		t.Errorf("MGF1-SHA256: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := SignPSS(rand.Reader, priv, crypto.SHA256, digest[:], &PSSOptions{MGFHash: crypto.MD4}); err == nil {
+		t.Errorf("SignPSS accepted an unavailable MGF hash")
+	}
+}
@@ -0,0 +1,306 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Threshold RSA signatures, Shoup's "Practical Threshold Signatures"
+// (EUROCRYPT 2000): a dealer splits D of a key of safe primes
+// p = 2p′ + 1, q = 2q′ + 1 into n shares with a polynomial of degree t - 1
+// over Z_m, m = p′q′. Any t parties produce a standard RSA signature, fewer
+// learn nothing of D, and no party ever holds D.
+//
+//	dealer                   DealThresholdKey(priv, n, t) -> ThresholdKey, KeyShare_1..n
+//	coordinator              em = ThresholdEncodePKCS1v15 / ThresholdEncodePSS
+//	party i                  share_i = KeyShare_i.Sign(em), x^(2Δs_i) and its proof
+//	anyone                   sig = ThresholdKey.Combine(em, t valid shares)
+//
+// With Δ = n! the Lagrange coefficients Δλ_{0,j} are integers, the shares
+// combine to w = x^(4Δ²D) and w^E = x^(4Δ²). E is a prime larger than n, so
+// a·4Δ² + b·E = 1 and y = w^a·x^b is x^D. Each share comes with a proof
+// that log_v v_i = log_{x^4Δ} x_i², so that wrong shares are dropped
+// before they spoil the signature.
+
+var (
+	errThresholdKey    = errors.New("simple_rsa: threshold signatures need a 2-prime key of safe primes")
+	errThresholdParams = errors.New("simple_rsa: invalid number of parties or threshold")
+	errThresholdShares = errors.New("simple_rsa: not enough valid signature shares")
+	errThresholdProof  = errors.New("simple_rsa: invalid signature share proof")
+)
+
+// thresholdHashBits is L1 of the paper, the length of the proof challenge
+const thresholdHashBits = 256
+
+// ThresholdKey is the public side of a shared key: the RSA public key, the
+// verification key V, a random square, and V_i = V^(s_i) of every party
+type ThresholdKey struct {
+	PublicKey
+	Parties, Threshold int
+
+	V                *big.Int
+	VerificationKeys []*big.Int // V_i of party i at index i - 1
+}
+
+// KeyShare is the secret share s_i = f(i) mod m of party i in [1, n]
+type KeyShare struct {
+	Index int
+	S     *big.Int
+}
+
+// SignatureShare is x_i = x^(2Δs_i) of party Index and the proof (C, Z)
+type SignatureShare struct {
+	Index int
+	X     *big.Int
+	C, Z  *big.Int
+}
+
+// safePrimeOrder returns m = p′q′ if priv has exactly the 2 safe primes
+// p = 2p′ + 1 and q = 2q′ + 1, nil otherwise. The squares mod N form a cyclic
+// group of order m.
+func safePrimeOrder(priv *PrivateKey) *big.Int {
+	if len(priv.Primes) != 2 {
+		return nil
+	}
+	m := big.NewInt(1)
+	for _, p := range priv.Primes {
+		half := new(big.Int).Rsh(p, 1)
+		if !half.ProbablyPrime(20) {
+			return nil
+		}
+		m.Mul(m, half)
+	}
+	return m
+}
+
+// factorial returns n!
+func factorial(n int) *big.Int {
+	return new(big.Int).MulRange(1, int64(n))
+}
+
+// DealThresholdKey splits priv into shares of the given number of parties,
+// any threshold of which can sign. priv needs 2 safe primes, as generated by
+// GeneratePartiallyBlindKey, and a prime E larger than parties.
+func DealThresholdKey(random io.Reader, priv *PrivateKey, parties, threshold int) (*ThresholdKey, []*KeyShare, error) {
+	if err := checkPub(&priv.PublicKey); err != nil {
+		return nil, nil, err
+	}
+	if threshold < 1 || parties < threshold || int64(parties) >= int64(priv.E) || !big.NewInt(int64(priv.E)).ProbablyPrime(20) {
+		return nil, nil, errThresholdParams
+	}
+	m := safePrimeOrder(priv)
+	if m == nil {
+		return nil, nil, errThresholdKey
+	}
+
+	// f(X) = d + a_1 X + ... + a_(t-1) X^(t-1) over Z_m
+	d := new(big.Int).ModInverse(big.NewInt(int64(priv.E)), m)
+	coefficients := []*big.Int{d}
+	for i := 1; i < threshold; i++ {
+		a, err := randomBelow(random, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients = append(coefficients, a)
+	}
+
+	// V generates the squares with overwhelming probability
+	var v *big.Int
+	for v == nil || v.Cmp(bigOne) == 0 {
+		r, err := randomBelow(random, priv.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		if modMultiInverse(r, priv.N) != nil {
+			v = r.Mul(r, r).Mod(r, priv.N)
+		}
+	}
+
+	key := &ThresholdKey{PublicKey: priv.PublicKey, Parties: parties, Threshold: threshold, V: v}
+	shares := make([]*KeyShare, parties)
+	for i := range shares {
+		x := big.NewInt(int64(i + 1))
+		s := new(big.Int)
+		for j := len(coefficients) - 1; j >= 0; j-- {
+			s.Mul(s, x).Add(s, coefficients[j]).Mod(s, m)
+		}
+		shares[i] = &KeyShare{Index: i + 1, S: s}
+		key.VerificationKeys = append(key.VerificationKeys, new(big.Int).Exp(v, s, priv.N))
+	}
+	return key, shares, nil
+}
+
+// ThresholdEncodePKCS1v15 returns the EMSA-PKCS1-v1_5 encoding every party
+// signs, the result of Combine verifies with VerifyPKCS1v15
+func ThresholdEncodePKCS1v15(pub *PublicKey, hash crypto.Hash, digest []byte) ([]byte, error) {
+	if err := checkPub(pub); err != nil {
+		return nil, err
+	}
+	return emsaPKCS1v15Encode(hash, digest, pub.Size())
+}
+
+// ThresholdEncodePSS returns the EMSA-PSS encoding every party signs, the
+// coordinator picks the salt once. The result of Combine verifies with
+// VerifyPSS.
+func ThresholdEncodePSS(random io.Reader, pub *PublicKey, hash crypto.Hash, digest []byte, opts *PSSOptions) ([]byte, error) {
+	if err := checkPub(pub); err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Hash != 0 {
+		hash = opts.Hash
+	}
+	mgf, err := opts.mgf(hash)
+	if err != nil {
+		return nil, err
+	}
+	return encodePSSWithSalt(random, pub.N.BitLen()-1, hash.New(), mgf, digest, opts.saltLength())
+}
+
+// messageRepresentative returns x = OS2IP(em), an invertible x < N
+func (key *ThresholdKey) messageRepresentative(em []byte) (*big.Int, error) {
+	x := new(big.Int).SetBytes(em)
+	if x.Cmp(key.N) >= 0 || modMultiInverse(x, key.N) == nil {
+		return nil, ErrMessageTooLong
+	}
+	return x, nil
+}
+
+// proofChallenge returns c = H(v, x~, v_i, x_i², v′, x′) of
+// thresholdHashBits bits
+func (key *ThresholdKey) proofChallenge(values ...*big.Int) *big.Int {
+	h := sha256.New()
+	buf := make([]byte, key.Size())
+	for _, x := range values {
+		h.Write(x.FillBytes(buf))
+	}
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
+
+// Sign returns the signature share x^(2Δs_i) of the encoded message em and
+// the proof that it uses the same s_i as V_i. Both exponentiations with
+// secret exponents run in constant time.
+func (share *KeyShare) Sign(random io.Reader, key *ThresholdKey, em []byte) (*SignatureShare, error) {
+	if share.Index < 1 || share.Index > key.Parties {
+		return nil, errThresholdParams
+	}
+	x, err := key.messageRepresentative(em)
+	if err != nil {
+		return nil, err
+	}
+	ctx := key.montgomery()
+	if ctx == nil {
+		return nil, errThresholdKey
+	}
+	delta := factorial(key.Parties)
+	exp := func(base, y *big.Int, ybits int) *big.Int {
+		return new(big.Int).SetBits(ctx.ctExp(base.Bits(), y, ybits))
+	}
+
+	// x_i = x^(2Δs_i)
+	twoDelta := new(big.Int).Lsh(delta, 1)
+	si := new(big.Int).Mul(share.S, twoDelta)
+	xi := exp(x, si, key.N.BitLen()+twoDelta.BitLen())
+
+	// prove log_v v_i = log_x~ x_i² with x~ = x^(4Δ): r of |N| + 2L1 bits,
+	// c = H(v, x~, v_i, x_i², v^r, x~^r), z = s_i·c + r
+	rbits := key.N.BitLen() + 2*thresholdHashBits
+	r, err := randomBelow(random, new(big.Int).Lsh(bigOne, uint(rbits)))
+	if err != nil {
+		return nil, err
+	}
+	xt := new(big.Int).Exp(x, new(big.Int).Lsh(delta, 2), key.N)
+	xi2 := new(big.Int).Mul(xi, xi)
+	xi2.Mod(xi2, key.N)
+	c := key.proofChallenge(key.V, xt, key.VerificationKeys[share.Index-1], xi2, exp(key.V, r, rbits), exp(xt, r, rbits))
+	z := new(big.Int).Mul(share.S, c)
+	z.Add(z, r)
+	return &SignatureShare{Index: share.Index, X: xi, C: c, Z: z}, nil
+}
+
+// VerifyShare checks the proof of a signature share of em:
+// c == H(v, x~, v_i, x_i², v^z·v_i^-c, x~^z·x_i^-2c)
+func (key *ThresholdKey) VerifyShare(em []byte, share *SignatureShare) error {
+	if share == nil || share.Index < 1 || share.Index > key.Parties || share.X == nil || share.C == nil || share.Z == nil {
+		return errThresholdProof
+	}
+	x, err := key.messageRepresentative(em)
+	if err != nil {
+		return err
+	}
+	if share.X.Sign() <= 0 || share.X.Cmp(key.N) >= 0 || share.Z.Sign() < 0 || share.C.BitLen() > thresholdHashBits {
+		return errThresholdProof
+	}
+	xt := new(big.Int).Exp(x, new(big.Int).Lsh(factorial(key.Parties), 2), key.N)
+	xi2 := new(big.Int).Mul(share.X, share.X)
+	xi2.Mod(xi2, key.N)
+	vi := key.VerificationKeys[share.Index-1]
+	negC := new(big.Int).Neg(share.C)
+
+	v1 := new(big.Int).Exp(vi, negC, key.N)
+	x1 := new(big.Int).Exp(xi2, negC, key.N)
+	if v1 == nil || x1 == nil {
+		return errThresholdProof
+	}
+	v1.Mul(v1, new(big.Int).Exp(key.V, share.Z, key.N)).Mod(v1, key.N)
+	x1.Mul(x1, new(big.Int).Exp(xt, share.Z, key.N)).Mod(x1, key.N)
+	if key.proofChallenge(key.V, xt, vi, xi2, v1, x1).Cmp(share.C) != 0 {
+		return errThresholdProof
+	}
+	return nil
+}
+
+// Combine verifies the signature shares of em, drops the invalid ones and
+// combines the first Threshold valid shares of distinct parties into the
+// k-octet signature em^D mod N
+func (key *ThresholdKey) Combine(em []byte, shares []*SignatureShare) ([]byte, error) {
+	x, err := key.messageRepresentative(em)
+	if err != nil {
+		return nil, err
+	}
+	var valid []*SignatureShare
+	seen := make(map[int]bool)
+	for _, share := range shares {
+		if len(valid) == key.Threshold {
+			break
+		}
+		if key.VerifyShare(em, share) != nil || seen[share.Index] {
+			continue
+		}
+		seen[share.Index] = true
+		valid = append(valid, share)
+	}
+	if len(valid) < key.Threshold {
+		return nil, errThresholdShares
+	}
+
+	// w = ∏ x_j^(2λ_j), λ_j = Δ ∏_(j′≠j) j′ / (j′ - j)
+	delta := factorial(key.Parties)
+	w := big.NewInt(1)
+	for _, share := range valid {
+		num, den := new(big.Int).Set(delta), big.NewInt(1)
+		for _, other := range valid {
+			if other.Index != share.Index {
+				num.Mul(num, big.NewInt(int64(other.Index)))
+				den.Mul(den, big.NewInt(int64(other.Index-share.Index)))
+			}
+		}
+		lambda := num.Quo(num, den)
+		w.Mul(w, new(big.Int).Exp(share.X, lambda.Lsh(lambda, 1), key.N)).Mod(w, key.N)
+	}
+
+	// y = w^a·x^b with a·4Δ² + b·E = 1
+	ePrime := new(big.Int).Mul(delta, delta)
+	ePrime.Lsh(ePrime, 2)
+	a, b := new(big.Int), new(big.Int)
+	new(big.Int).GCD(a, b, ePrime, big.NewInt(int64(key.E)))
+	y := new(big.Int).Exp(w, a, key.N)
+	y.Mul(y, new(big.Int).Exp(x, b, key.N)).Mod(y, key.N)
+
+	if encrypt(&key.PublicKey, y).Cmp(x) != 0 {
+		return nil, errInternal
+	}
+	return y.FillBytes(make([]byte, key.Size())), nil
+}
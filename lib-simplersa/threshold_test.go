@@ -0,0 +1,93 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func TestThresholdSignatures(t *testing.T) {
+	priv, err := GeneratePartiallyBlindKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, shares, err := DealThresholdKey(rand.Reader, priv, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &key.PublicKey
+	digest := sha256.Sum256([]byte("no single machine holds D"))
+
+	// the parties sign in-process, each with its own share only
+	sign := func(em []byte, parties ...int) []*SignatureShare {
+		var out []*SignatureShare
+		for _, i := range parties {
+			share, err := shares[i-1].Sign(rand.Reader, key, em)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := key.VerifyShare(em, share); err != nil {
+				t.Errorf("party %d: %s", i, err)
+			}
+			out = append(out, share)
+		}
+		return out
+	}
+
+	em, err := ThresholdEncodePKCS1v15(pub, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parties := range [][]int{{1, 2, 3}, {2, 4, 5}, {5, 1, 3, 4}} {
+		sig, err := key.Combine(em, sign(em, parties...))
+		if err != nil {
+			t.Fatalf("parties %v: %s", parties, err)
+		}
+		if err := VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			t.Errorf("parties %v: VerifyPKCS1v15: %s", parties, err)
+		}
+	}
+	if _, err := key.Combine(em, sign(em, 1, 4)); err != errThresholdShares {
+		t.Errorf("2 of 3 shares: got %v, want %v", err, errThresholdShares)
+	}
+
+	opts := &PSSOptions{SaltLength: PSSSaltLengthEqualsHash}
+	em, err = ThresholdEncodePSS(rand.Reader, pub, crypto.SHA256, digest[:], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := sign(em, 1, 2, 3, 4)
+	// a wrong share and a repeated party are dropped, the valid ones remain
+	signed[0].X = new(big.Int).Add(signed[0].X, bigOne)
+	if key.VerifyShare(em, signed[0]) == nil {
+		t.Errorf("a wrong share passes its proof")
+	}
+	signed = append([]*SignatureShare{signed[0], signed[1]}, signed[1:]...)
+	sig, err := key.Combine(em, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPSS(pub, crypto.SHA256, digest[:], sig, opts); err != nil {
+		t.Errorf("VerifyPSS: %s", err)
+	}
+	// a share of one message does not prove anything of another
+	other := sign(em, 5)[0]
+	em2, _ := ThresholdEncodePKCS1v15(pub, crypto.SHA256, digest[:])
+	if key.VerifyShare(em2, other) == nil {
+		t.Errorf("a share verifies for another message")
+	}
+}
+
+func TestDealThresholdKey(t *testing.T) {
+	plain, _ := GenerateKey(rand.Reader, 1024)
+	if _, _, err := DealThresholdKey(rand.Reader, plain, 3, 2); err != errThresholdKey {
+		t.Errorf("a key of ordinary primes: got %v, want %v", err, errThresholdKey)
+	}
+	for _, test := range []struct{ parties, threshold int }{{3, 0}, {2, 3}, {65537, 2}} {
+		if _, _, err := DealThresholdKey(rand.Reader, plain, test.parties, test.threshold); err != errThresholdParams {
+			t.Errorf("%d parties, threshold %d: got %v, want %v", test.parties, test.threshold, err, errThresholdParams)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/subtle"
 	"fmt"
 	"math/big"
+	"sync"
 	"testing"
 )
 
@@ -109,22 +110,14 @@ func TestExgcd(t *testing.T) {
 }
 
 func TestRandomPrime1024(t *testing.T) {
-	if _, err := randomPrime(rand.Reader, 1); err == nil {
-		t.Errorf("Return no err when random prime with bits < 2")
-	}
-
-	if _, err := randomPrime(rand.Reader, -100); err == nil {
-		t.Errorf("Return no err when random prime with bits < 2")
-	}
-
 	size, times := 1024, 100
 	if testing.Short() {
 		size = 128
 	}
 
 	for i := 0; i < times; i++ {
-		ParaCalc = (i%2 == 0)
-		prime, err := randomPrime(rand.Reader, size)
+		opts := &KeyGenOptions{Random: rand.Reader, Parallel: i%2 == 0}
+		prime, err := opts.randomPrime(size)
 		if err != nil {
 			t.Errorf("failed to random a prime: %s", err)
 		} else {
@@ -141,8 +134,8 @@ func TestRandomPrime2048(t *testing.T) {
 		size = 128
 	}
 	for i := 0; i < times; i++ {
-		ParaCalc = (i%2 == 0)
-		prime, err := randomPrime(rand.Reader, size)
+		opts := &KeyGenOptions{Random: rand.Reader, Parallel: i%2 == 0}
+		prime, err := opts.randomPrime(size)
 		if err != nil {
 			t.Errorf("failed to random a prime: %s", err)
 		} else {
@@ -153,93 +146,27 @@ func TestRandomPrime2048(t *testing.T) {
 	}
 }
 
-func TestProbablyPrime(t *testing.T) {
-	isPrimes := map[*big.Int]bool{
-		big.NewInt(0): false,
-		big.NewInt(1): false,
-		big.NewInt(2): true,
-	}
-	var smallPrimes = []int64{
-		3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
-	}
-	for _, sp := range smallPrimes {
-		isPrimes[big.NewInt(sp)] = true
-	}
-
-	bits := []int{64, 128, 512, 1024, 2048}
-	for _, bit := range bits {
-		// prime
-		p, err := rand.Prime(rand.Reader, bit)
-		if err == nil {
-			isPrimes[new(big.Int).Set(p)] = true
-			t.Log("prime: ", p.BitLen())
-		}
-		// maybe not prime
-		num, err := rand.Int(rand.Reader, p)
-		if err == nil {
-			isPrimes[new(big.Int).Set(num)] = num.ProbablyPrime(20)
-			t.Log("number: ", num.BitLen())
-		}
-	}
-
-	t.Log("testcase:", len(isPrimes))
-
-	for val, isP := range isPrimes {
-		if probablyPrime(val, 20) != isP {
-			t.Errorf("failed to judge prime: %v is (%v) prime", val, isP)
-		}
-	}
-}
-
-func BenchmarkProbablyPrime(b *testing.B) {
-	b.StopTimer()
-	var primeBits = []int{128, 512, 1024, 2048}
-	var primes []struct {
-		bit int
-		p   *big.Int
-	}
-	for _, bit := range primeBits {
-		p, _ := rand.Prime(rand.Reader, bit)
-		primes = append(primes, struct {
-			bit int
-			p   *big.Int
-		}{bit: bit, p: p})
-	}
-	for _, P := range primes {
-		testName := fmt.Sprintf("P=%d", P.bit)
-		b.Run(testName, func(bs *testing.B) {
-			bs.StartTimer()
-			for i := 0; i < bs.N; i++ {
-				probablyPrime(P.p, 20)
-			}
-		})
-	}
-}
-
-func BenchmarkRandomPrime(b *testing.B) {
-	var primeBits = []int{128, 512, 1024, 2048}
-	for _, bit := range primeBits {
-		testName := fmt.Sprintf("P=%d", bit)
-		b.Run(testName, func(bs *testing.B) {
-			bs.StartTimer()
-			for i := 0; i < bs.N; i++ {
-				randomPrime(rand.Reader, bit)
+func TestRandomPrimeParallel(t *testing.T) {
+	// Run with -race: several parallel searches share nothing but the
+	// goroutine pools they create.
+	size, times := 768, 8
+	var wg sync.WaitGroup
+	for i := 0; i < times; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opts := &KeyGenOptions{Random: rand.Reader, Parallel: true, Workers: 2 + i%3, ChunkSize: uint64(20 + 10*i)}
+			prime, err := opts.randomPrime(size)
+			if err != nil {
+				t.Errorf("failed to random a prime: %s", err)
+				return
 			}
-		})
-	}
-}
-
-func BenchmarkStdRandomPrime(b *testing.B) {
-	var primeBits = []int{128, 512, 1024, 2048, 3072}
-	for _, bit := range primeBits {
-		testName := fmt.Sprintf("P=%d", bit)
-		b.Run(testName, func(bs *testing.B) {
-			bs.StartTimer()
-			for i := 0; i < bs.N; i++ {
-				rand.Prime(rand.Reader, bit)
+			if prime.BitLen() != size || !prime.ProbablyPrime(20) {
+				t.Errorf("the random number is not a %d-bit prime", size)
 			}
-		})
+		}(i)
 	}
+	wg.Wait()
 }
 
 func TestBigModSqr(t *testing.T) {
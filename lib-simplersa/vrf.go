@@ -0,0 +1,134 @@
+package lib_simplersa
+
+import (
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// RSA-FDH-VRF of RFC 9381, section 4: a verifiable random function, the
+// proof pi is the RSA full domain hash signature of alpha and the output
+// beta is the hash of pi. Only the holder of D computes beta, anyone checks
+// it with N and E. RSA signatures are unique, so each alpha has exactly one
+// beta per key.
+//
+//	EM   = MGF1(suite || 0x01 || I2OSP(k, 4) || I2OSP(N, k) || alpha, k - 1)
+//	pi   = I2OSP(OS2IP(EM)^D mod N, k)
+//	beta = Hash(suite || 0x02 || pi)
+//
+// The uniqueness relies on N and E being an honestly generated key, such
+// as those of GenerateKey.
+
+var errVRFSuite = errors.New("simple_rsa: unknown RSA-FDH-VRF ciphersuite")
+
+// VRFSuite is an RSA-FDH-VRF ciphersuite of RFC 9381, its value is the
+// suite_string
+type VRFSuite byte
+
+const (
+	// VRFSHA256 is RSA-FDH-VRF-SHA256
+	VRFSHA256 VRFSuite = iota + 1
+	// VRFSHA384 is RSA-FDH-VRF-SHA384
+	VRFSHA384
+	// VRFSHA512 is RSA-FDH-VRF-SHA512
+	VRFSHA512
+)
+
+func (s VRFSuite) String() string {
+	switch s {
+	case VRFSHA256:
+		return "RSA-FDH-VRF-SHA256"
+	case VRFSHA384:
+		return "RSA-FDH-VRF-SHA384"
+	case VRFSHA512:
+		return "RSA-FDH-VRF-SHA512"
+	}
+	return "unknown RSA-FDH-VRF ciphersuite"
+}
+
+// hash returns the hash function of the suite, 0 for an unknown suite
+func (s VRFSuite) hash() crypto.Hash {
+	switch s {
+	case VRFSHA256:
+		return crypto.SHA256
+	case VRFSHA384:
+		return crypto.SHA384
+	case VRFSHA512:
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// vrfEncode returns the full domain hash of alpha, MGF1 of k - 1 octets
+func vrfEncode(pub *PublicKey, suite VRFSuite, alpha []byte) (*big.Int, error) {
+	k := pub.Size()
+	seed := make([]byte, 6, 6+k+len(alpha))
+	seed[0], seed[1] = byte(suite), 0x01
+	binary.BigEndian.PutUint32(seed[2:], uint32(k))
+	seed = append(seed, pub.N.FillBytes(make([]byte, k))...)
+	seed = append(seed, alpha...)
+
+	em := make([]byte, k-1)
+	if err := mgf1XOR(em, suite.hash().New(), seed); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(em), nil
+}
+
+// VRFProve returns the proof pi of alpha, the raw private operation on the
+// full domain hash of alpha
+func VRFProve(random io.Reader, priv *PrivateKey, suite VRFSuite, alpha []byte) (pi []byte, err error) {
+	if err = checkPub(&priv.PublicKey); err != nil {
+		return nil, err
+	}
+	if suite.hash() == 0 {
+		return nil, errVRFSuite
+	}
+	m, err := vrfEncode(&priv.PublicKey, suite, alpha)
+	if err != nil {
+		return nil, err
+	}
+	s, err := decrypt(random, priv, m)
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, priv.Size())), nil
+}
+
+// VRFProofToHash returns the output beta of a proof, it does not verify pi
+func VRFProofToHash(suite VRFSuite, pi []byte) (beta []byte, err error) {
+	if suite.hash() == 0 {
+		return nil, errVRFSuite
+	}
+	h := suite.hash().New()
+	h.Write([]byte{byte(suite), 0x02})
+	h.Write(pi)
+	return h.Sum(nil), nil
+}
+
+// VRFVerify verifies the proof pi of alpha and returns its output beta
+func VRFVerify(pub *PublicKey, suite VRFSuite, alpha, pi []byte) (beta []byte, err error) {
+	if err = checkPub(pub); err != nil {
+		return nil, err
+	}
+	if suite.hash() == 0 {
+		return nil, errVRFSuite
+	}
+	if len(pi) != pub.Size() {
+		return nil, ErrVerification
+	}
+	s := new(big.Int).SetBytes(pi)
+	if s.Cmp(pub.N) >= 0 {
+		return nil, ErrVerification
+	}
+	want, err := vrfEncode(pub, suite, alpha)
+	if err != nil {
+		return nil, err
+	}
+	if encrypt(pub, s).Cmp(want) != 0 {
+		return nil, ErrVerification
+	}
+	return VRFProofToHash(suite, pi)
+}
@@ -0,0 +1,87 @@
+package lib_simplersa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+)
+
+// RFC 9381 has test vectors of ECVRF only, these are regression data recorded
+// from this implementation on the key of RFC 9474, not independent vectors
+type rsaVRFVectors struct {
+	N, E, D, P, Q string
+	Vectors       []struct {
+		Suite VRFSuite
+		Alpha string
+		Pi    string
+		Beta  string
+	}
+}
+
+func TestVRFVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/rsafdhvrf.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors rsaVRFVectors
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	priv := &PrivateKey{
+		PublicKey: PublicKey{N: vectorInt(vectors.N), E: int(vectorInt(vectors.E).Int64())},
+		D:         vectorInt(vectors.D),
+		Primes:    []*big.Int{vectorInt(vectors.P), vectorInt(vectors.Q)},
+	}
+	priv.Precompute()
+	pub := &priv.PublicKey
+
+	for _, vec := range vectors.Vectors {
+		alpha := fromHex(vec.Alpha)
+		pi, err := VRFProve(rand.Reader, priv, vec.Suite, alpha)
+		if err != nil || !bytes.Equal(pi, fromHex(vec.Pi)) {
+			t.Errorf("%s %q: VRFProve = %x, %v", vec.Suite, alpha, pi, err)
+			continue
+		}
+		if beta, err := VRFProofToHash(vec.Suite, pi); err != nil || !bytes.Equal(beta, fromHex(vec.Beta)) {
+			t.Errorf("%s %q: VRFProofToHash = %x, %v", vec.Suite, alpha, beta, err)
+		}
+		if beta, err := VRFVerify(pub, vec.Suite, alpha, pi); err != nil || !bytes.Equal(beta, fromHex(vec.Beta)) {
+			t.Errorf("%s %q: VRFVerify = %x, %v", vec.Suite, alpha, beta, err)
+		}
+	}
+}
+
+func TestVRF(t *testing.T) {
+	priv, err := GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	alpha := []byte("round 42")
+	pi, err := VRFProve(rand.Reader, priv, VRFSHA256, alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// deterministic: one output per input
+	if again, _ := VRFProve(nil, priv, VRFSHA256, alpha); !bytes.Equal(again, pi) {
+		t.Errorf("two proofs of the same input differ")
+	}
+	if _, err := VRFVerify(pub, VRFSHA256, alpha, pi); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VRFVerify(pub, VRFSHA256, []byte("round 43"), pi); err != ErrVerification {
+		t.Errorf("another input: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := VRFVerify(pub, VRFSHA384, alpha, pi); err != ErrVerification {
+		t.Errorf("another suite: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := VRFVerify(pub, VRFSHA256, alpha, pi[1:]); err != ErrVerification {
+		t.Errorf("a short proof: got %v, want %v", err, ErrVerification)
+	}
+	if _, err := VRFProve(rand.Reader, priv, VRFSuite(4), alpha); err != errVRFSuite {
+		t.Errorf("an unknown suite: got %v, want %v", err, errVRFSuite)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rand"
 	"embed"
@@ -8,27 +9,51 @@ import (
 	"fmt"
 	"github.com/zserge/lorca"
 	"log"
+	"math/big"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	simplersa "simple-rsa/lib-simplersa"
+	"strconv"
+	"strings"
+	"time"
 )
 
 //go:embed www
 var fs embed.FS
 
 var key_bits, key_nprimes int
+var key_parallel bool
 var priv *simplersa.PrivateKey
 
-func GenerateRSAKey(nprimes, bits int) {
-	var err error
-	key_nprimes, key_bits = nprimes, bits
-	priv, err = simplersa.GenerateMultiPrimeKey(rand.Reader, nprimes, bits)
+// GenerateRSAKey returns an error message, or "" when the key is generated.
+// primeSizes is an optional comma separated list of prime bit lengths.
+func GenerateRSAKey(nprimes, bits, e int, primeSizes string) string {
+	opts := &simplersa.KeyGenOptions{
+		Parallel: key_parallel,
+		Random:   rand.Reader,
+		E:        e,
+		NPrimes:  nprimes,
+	}
+	if primeSizes = strings.TrimSpace(primeSizes); primeSizes != "" {
+		for _, size := range strings.Split(primeSizes, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				return fmt.Sprintf("Bad Prime Sizes %q 💢💢💢", primeSizes)
+			}
+			opts.PrimeSizes = append(opts.PrimeSizes, n)
+		}
+	}
+
+	key, err := simplersa.GenerateKeyWithOptions(bits, opts)
 	if err != nil {
-		return
+		return fmt.Sprintf("Generate Key Error: %s 💢💢💢", err)
 	}
+	key_nprimes, key_bits = len(key.Primes), bits
+	priv = key
+	return ""
 	//return priv.N.String(), priv.D.String(), string(priv.E)
 }
 
@@ -84,6 +109,308 @@ func GetPrimes(hex bool) []string {
 	return strPrimeList
 }
 
+// AuditItem is an audit finding as shown by the UI
+type AuditItem struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// AuditKey audits the current key, the first item sums up the report
+func AuditKey() []AuditItem {
+	if priv == nil {
+		return []AuditItem{{"key", "critical", ErrNoKey}}
+	}
+	report := simplersa.AuditPrivateKey(priv)
+	items := []AuditItem{{"summary", report.Severity().String(), fmt.Sprintf("%d-bit key of %d primes", report.Bits, report.NPrimes)}}
+	for _, f := range report.Findings {
+		items = append(items, AuditItem{f.Check, f.Severity.String(), f.Message})
+	}
+	return items
+}
+
+// FactorMethodNames lists the factoring algorithms of the attack lab
+func FactorMethodNames() []string {
+	var names []string
+	for _, m := range simplersa.FactorMethods {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// AttackResult is the outcome of a factoring attack as shown by the UI
+type AttackResult struct {
+	Method string   `json:"method"`
+	Millis float64  `json:"millis"`
+	Primes []string `json:"primes"`
+	Error  string   `json:"error"`
+}
+
+// FactorKey factors N of the current key with the i-th factoring method
+func FactorKey(i int) AttackResult {
+	if priv == nil {
+		return AttackResult{Error: ErrNoKey}
+	}
+	if i < 0 || i >= len(simplersa.FactorMethods) {
+		return AttackResult{Error: "Unknown Method 💢💢💢"}
+	}
+	result, err := simplersa.Factorize(rand.Reader, priv.N, simplersa.FactorMethods[i])
+	attack := AttackResult{Method: result.Method, Millis: float64(result.Elapsed.Microseconds()) / 1000}
+	if err != nil {
+		attack.Error = err.Error()
+	}
+	for _, p := range result.Primes {
+		attack.Primes = append(attack.Primes, p.String())
+	}
+	return attack
+}
+
+// AttackDemos lists the walkthroughs of the structural attacks
+var AttackDemos = map[string]func() ([]string, error){
+	"wiener":         wienerDemo,
+	"hastad":         hastadDemo,
+	"commonModulus":  commonModulusDemo,
+	"bleichenbacher": bleichenbacherDemo,
+}
+
+// RunAttackDemo runs a walkthrough on fresh keys and returns its step log
+func RunAttackDemo(name string) []string {
+	demo, ok := AttackDemos[name]
+	if !ok {
+		return []string{"Unknown Attack 💢💢💢"}
+	}
+	steps, err := demo()
+	if err != nil {
+		steps = append(steps, fmt.Sprintf("❌ %s", err))
+	}
+	return steps
+}
+
+func wienerDemo() ([]string, error) {
+	p, _ := rand.Prime(rand.Reader, 512)
+	q, _ := rand.Prime(rand.Reader, 512)
+	n := new(big.Int).Mul(p, q)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, big.NewInt(1)), new(big.Int).Sub(q, big.NewInt(1)))
+	var d, e *big.Int
+	for e == nil {
+		d, _ = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 240))
+		e = new(big.Int).ModInverse(d.SetBit(d, 0, 1), phi)
+	}
+	steps := []string{
+		fmt.Sprintf("1. 1024-bit N = %x", n),
+		fmt.Sprintf("2. a 240-bit D < N^(1/4) / 3 is chosen, E = D^-1 mod φ(N) = %x", e),
+		"3. expand E / N into a continued fraction, test every convergent k / D",
+	}
+	gotD, gotP, gotQ, err := simplersa.WienerAttack(n, e)
+	if err != nil {
+		return steps, err
+	}
+	return append(steps,
+		"4. φ = (E·D - 1) / k gives p + q, p and q are the roots of x² - (p + q)x + N",
+		fmt.Sprintf("✔️ D = %x, equal: %t", gotD, gotD.Cmp(d) == 0),
+		fmt.Sprintf("✔️ p = %x", gotP),
+		fmt.Sprintf("✔️ q = %x", gotQ),
+	), nil
+}
+
+func hastadDemo() ([]string, error) {
+	// random bytes ahead of the message make m³ wrap around every N
+	block := make([]byte, 100, 114)
+	rand.Read(block)
+	block[0] |= 0x80
+	block = append(block, "attack at dawn"...)
+	m := new(big.Int).SetBytes(block)
+	var steps []string
+	var pubs []*simplersa.PublicKey
+	var cs []*big.Int
+	for i := 0; i < 3; i++ {
+		key, err := simplersa.GenerateKeyWithOptions(1024, &simplersa.KeyGenOptions{Random: rand.Reader, E: 3})
+		if err != nil {
+			return steps, err
+		}
+		pub := &key.PublicKey
+		// textbook RSA, as the library's raw encrypt primitive
+		c := new(big.Int).Exp(m, big.NewInt(int64(pub.E)), pub.N)
+		pubs, cs = append(pubs, pub), append(cs, c)
+		steps = append(steps, fmt.Sprintf("%d. the 114-byte m = %x… is sent unpadded with E = 3 to N%d = %x…, c%d = %x…", i+1, block[:8], i+1, pub.N.Bytes()[:8], i+1, c.Bytes()[:8]))
+	}
+	steps = append(steps, "4. the CRT gives m³ mod N1·N2·N3, m³ is smaller, so it is m³ itself")
+	got, err := simplersa.HastadBroadcast(pubs, cs)
+	if err != nil {
+		return steps, err
+	}
+	return append(steps, fmt.Sprintf("✔️ the integer cube root is %x…, ending with %q", got.Bytes()[:8], got.Bytes()[100:])), nil
+}
+
+func commonModulusDemo() ([]string, error) {
+	key, err := simplersa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		return nil, err
+	}
+	msg := []byte("attack at dawn")
+	m := new(big.Int).SetBytes(msg)
+	pub1, pub2 := &key.PublicKey, &simplersa.PublicKey{N: key.N, E: 3}
+	c1 := new(big.Int).Exp(m, big.NewInt(int64(pub1.E)), pub1.N)
+	c2 := new(big.Int).Exp(m, big.NewInt(int64(pub2.E)), pub2.N)
+	steps := []string{
+		fmt.Sprintf("1. two users share N = %x…", key.N.Bytes()[:8]),
+		fmt.Sprintf("2. %q is sent unpadded with E1 = %d and E2 = %d", msg, pub1.E, pub2.E),
+		"3. gcd(E1, E2) = 1, so a·E1 + b·E2 = 1, and c1^a · c2^b = m^(a·E1 + b·E2) = m",
+	}
+	got, err := simplersa.CommonModulusAttack(pub1, pub2, c1, c2)
+	if err != nil {
+		return steps, err
+	}
+	return append(steps, fmt.Sprintf("✔️ m = %q", got.Bytes())), nil
+}
+
+func bleichenbacherDemo() ([]string, error) {
+	key, err := simplersa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		return nil, err
+	}
+	msg := []byte("attack at dawn")
+	c, err := simplersa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	steps := []string{
+		fmt.Sprintf("1. %q is encrypted with EME-PKCS1-v1_5 under a 512-bit key", msg),
+		"2. the server tells whether DecryptPKCS1v15 fails, every c·s^E it accepts puts m·s mod N in [2B, 3B)",
+		"3. search s, narrow the intervals of m until one is left",
+	}
+	start := time.Now()
+	em, queries, err := simplersa.BleichenbacherAttack(rand.Reader, &key.PublicKey, c, simplersa.LeakyPKCS1v15Oracle(key))
+	if err != nil {
+		return steps, err
+	}
+	steps = append(steps, fmt.Sprintf("4. %d oracle queries in %s, EM = %x", queries, time.Since(start).Round(time.Millisecond), em))
+	// EM = 0x00 || 0x02 || PS || 0x00 || M
+	if i := bytes.IndexByte(em[2:], 0); i >= 0 {
+		steps = append(steps, fmt.Sprintf("✔️ M = %q", em[2+i+1:]))
+	}
+	return steps, nil
+}
+
+// BlindVariantNames lists the RSABSSA variants of the blind signature demo
+func BlindVariantNames() []string {
+	var names []string
+	for v := simplersa.BlindSHA384PSSRandomized; v <= simplersa.BlindSHA384PSSZeroDeterministic; v++ {
+		names = append(names, v.String())
+	}
+	return names
+}
+
+// BlindView is what the client and signer panes show at each step
+type BlindView struct {
+	Prepared string `json:"prepared"`
+	Blinded  string `json:"blinded"`
+	Inv      string `json:"inv"`
+	BlindSig string `json:"blindSig"`
+	Sig      string `json:"sig"`
+	Error    string `json:"error"`
+}
+
+// blindClient is the state the client keeps between blinding and finalizing
+var blindClient struct {
+	variant                     simplersa.BlindVariant
+	prepared, blinded, blindSig []byte
+	inv                         *big.Int
+}
+
+// BlindMessage prepares and blinds msg on the client side
+func BlindMessage(msg string, variant int) BlindView {
+	if priv == nil {
+		return BlindView{Error: ErrNoKey}
+	}
+	v := simplersa.BlindVariant(variant)
+	prepared, err := simplersa.BlindPrepare(rand.Reader, v, []byte(msg))
+	if err != nil {
+		return BlindView{Error: err.Error()}
+	}
+	blinded, inv, err := simplersa.Blind(rand.Reader, &priv.PublicKey, v, prepared)
+	if err != nil {
+		return BlindView{Error: err.Error()}
+	}
+	blindClient.variant, blindClient.prepared, blindClient.blinded, blindClient.inv = v, prepared, blinded, inv
+	blindClient.blindSig = nil
+	return BlindView{Prepared: hex.EncodeToString(prepared), Blinded: hex.EncodeToString(blinded), Inv: inv.Text(16)}
+}
+
+// SignBlinded signs the blinded message on the signer side, the signer
+// only ever sees the blinded value
+func SignBlinded() BlindView {
+	if priv == nil {
+		return BlindView{Error: ErrNoKey}
+	}
+	if blindClient.blinded == nil {
+		return BlindView{Error: "Blind a Message First 💢💢💢"}
+	}
+	blindSig, err := simplersa.BlindSign(rand.Reader, priv, blindClient.blinded)
+	if err != nil {
+		return BlindView{Error: err.Error()}
+	}
+	blindClient.blindSig = blindSig
+	return BlindView{Blinded: hex.EncodeToString(blindClient.blinded), BlindSig: hex.EncodeToString(blindSig)}
+}
+
+// FinalizeBlind unblinds the blind signature on the client side and
+// verifies it
+func FinalizeBlind() BlindView {
+	if priv == nil {
+		return BlindView{Error: ErrNoKey}
+	}
+	if blindClient.blindSig == nil {
+		return BlindView{Error: "Sign the Blinded Message First 💢💢💢"}
+	}
+	sig, err := simplersa.BlindFinalize(&priv.PublicKey, blindClient.variant, blindClient.prepared, blindClient.blindSig, blindClient.inv)
+	if err != nil {
+		return BlindView{Error: VerifyFalse + err.Error()}
+	}
+	return BlindView{BlindSig: hex.EncodeToString(blindClient.blindSig), Sig: hex.EncodeToString(sig)}
+}
+
+// BackupView is what the key backup dialog shows
+type BackupView struct {
+	Shares []string `json:"shares"`
+	Status string   `json:"status"`
+	Error  string   `json:"error"`
+}
+
+// ExportKeyShares splits the current key into n printable shares, any t of
+// which restore it
+func ExportKeyShares(n, t int) BackupView {
+	if priv == nil {
+		return BackupView{Error: ErrNoKey}
+	}
+	shares, err := simplersa.SplitPrivateKey(rand.Reader, priv, n, t)
+	if err != nil {
+		return BackupView{Error: err.Error()}
+	}
+	return BackupView{Shares: shares, Status: fmt.Sprintf("Any %d of the %d shares restore the key, keep them apart 🧩", t, n)}
+}
+
+// RestoreKeyShares restores a key from shares, one per line, and makes it
+// the current key
+func RestoreKeyShares(text string) BackupView {
+	var shares []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			shares = append(shares, line)
+		}
+	}
+	key, err := simplersa.CombinePrivateKey(shares)
+	if err != nil {
+		return BackupView{Error: fmt.Sprintf("Restore Error: %s 💢💢💢", err)}
+	}
+	status := "✔️ Key Restored and Validated 🎉🎉🎉"
+	if priv != nil && priv.Equal(key) {
+		status = "✔️ Restored Key Equals the Current Key 🎉🎉🎉"
+	}
+	priv, key_nprimes, key_bits = key, len(key.Primes), key.N.BitLen()
+	return BackupView{Status: status}
+}
+
 var (
 	ErrNoKey    = "Please Generate a RSA Key \U0001FA84\U0001FA84\U0001FA84"
 	ErrDecrypt  = "Decrypt Error 💢💢💢"
@@ -93,12 +420,21 @@ var (
 	VerifyFalse = "❌ Signature is Wrong ⛔⛔⛔ "
 )
 
+// uiHashes are the hash functions of the Hash Function dropdowns, all with
+// a DigestInfo prefix for EMSA-PKCS1-v1_5; the value of an option is the
+// String of its hash
+var uiHashes = []crypto.Hash{
+	crypto.MD5, crypto.SHA1,
+	crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512, crypto.SHA512_224, crypto.SHA512_256,
+	crypto.SHA3_224, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512,
+}
+
 var str2hash map[string]crypto.Hash
 
 func getCryptoHash(hashName string) crypto.Hash {
 	if str2hash == nil {
 		str2hash = make(map[string]crypto.Hash)
-		for h := crypto.MD4; h <= crypto.BLAKE2b_512; h++ {
+		for _, h := range uiHashes {
 			str2hash[h.String()] = h
 		}
 	}
@@ -109,7 +445,27 @@ func getCryptoHash(hashName string) crypto.Hash {
 	}
 }
 
-func Encrypt(plaintext string, isUseOAEP bool, OAEPLabel string, hashName string) string {
+// getMGFHash returns the hash of an MGF1 Hash dropdown, 0 for the empty
+// value "Same as Hash"
+func getMGFHash(hashName string) crypto.Hash {
+	if hashName == "" {
+		return 0
+	}
+	return getCryptoHash(hashName)
+}
+
+// getSHAKE returns the XOF of RSASSA-PSS with SHAKE, the extra options of
+// the sign dropdown, or 0
+func getSHAKE(hashName string) simplersa.SHAKE {
+	for _, x := range []simplersa.SHAKE{simplersa.SHAKE128, simplersa.SHAKE256} {
+		if hashName == x.String() {
+			return x
+		}
+	}
+	return 0
+}
+
+func Encrypt(plaintext string, isUseOAEP bool, OAEPLabel string, hashName string, mgfHashName string) string {
 	if priv == nil {
 		return ErrNoKey
 	}
@@ -124,7 +480,8 @@ func Encrypt(plaintext string, isUseOAEP bool, OAEPLabel string, hashName string
 		if OAEPLabel != "" {
 			label = []byte(OAEPLabel)
 		}
-		ciphertext, err = simplersa.EncryptOAEP(hash.New(), rng, &priv.PublicKey, msg, label)
+		opts := &simplersa.OAEPOptions{Hash: hash, MGFHash: getMGFHash(mgfHashName), Label: label}
+		ciphertext, err = simplersa.EncryptOAEPWithOptions(rng, &priv.PublicKey, msg, opts)
 	} else {
 		ciphertext, err = simplersa.EncryptPKCS1v15(rng, &priv.PublicKey, msg)
 	}
@@ -135,7 +492,7 @@ func Encrypt(plaintext string, isUseOAEP bool, OAEPLabel string, hashName string
 	return fmt.Sprintf("%x", ciphertext)
 }
 
-func Decrypt(c string, isUseOAEP bool, OAEPLabel string, hashName string) string {
+func Decrypt(c string, isUseOAEP bool, OAEPLabel string, hashName string, mgfHashName string) string {
 	if priv == nil {
 		return ErrNoKey
 	}
@@ -154,7 +511,8 @@ func Decrypt(c string, isUseOAEP bool, OAEPLabel string, hashName string) string
 		if OAEPLabel != "" {
 			label = []byte(OAEPLabel)
 		}
-		plaintext, err = simplersa.DecryptOAEP(hash.New(), rng, priv, ciphertext, label)
+		opts := &simplersa.OAEPOptions{Hash: hash, MGFHash: getMGFHash(mgfHashName), Label: label}
+		plaintext, err = simplersa.DecryptOAEPWithOptions(rng, priv, ciphertext, opts)
 	} else {
 		plaintext, err = simplersa.DecryptPKCS1v15(rng, priv, ciphertext)
 	}
@@ -165,25 +523,53 @@ func Decrypt(c string, isUseOAEP bool, OAEPLabel string, hashName string) string
 	return fmt.Sprintf("%s", plaintext)
 }
 
-func Sign(plaintext string, hashName string, isUsePSS bool, saltLength int) string {
+// signature schemes, the values of the radioSignPKCS buttons
+const (
+	schemePKCS1v15 = iota + 1
+	schemePSS
+	schemeFDH
+	schemeISO9796Scheme1
+	schemeISO9796Scheme2
+)
+
+func Sign(plaintext string, hashName string, scheme int, saltLength int, mgfHashName string) string {
 	if priv == nil {
 		return ErrNoKey
 	}
 	msg, rng, hash := []byte(plaintext), rand.Reader, getCryptoHash(hashName)
 	var signature []byte
 	var err error
-	//fmt.Println("Sign: hashName", hashName,hash.String(), "isUsePSS", isUsePSS, "saltLength", saltLength)
+	//fmt.Println("Sign: hashName", hashName,hash.String(), "scheme", scheme, "saltLength", saltLength)
+
+	// SHAKE is only defined for RSASSA-PSS, with its own salt length
+	if x := getSHAKE(hashName); x != 0 {
+		if scheme != schemePSS {
+			return ErrSign
+		}
+		signature, err := simplersa.SignPSSWithSHAKE(rng, priv, x, x.Sum(msg))
+		if err != nil {
+			return ErrSign
+		}
+		return fmt.Sprintf("%x", signature)
+	}
 
 	hashFunc := hash.New()
 	hashFunc.Write(msg)
 	digest := hashFunc.Sum(nil)
 
-	if isUsePSS {
+	switch scheme {
+	case schemePSS:
 		if saltLength < -1 {
 			saltLength = 0
 		}
-		signature, err = simplersa.SignPSS(rng, priv, hash, digest[:], &simplersa.PSSOptions{SaltLength: saltLength, Hash: hash})
-	} else {
+		signature, err = simplersa.SignPSS(rng, priv, hash, digest[:], &simplersa.PSSOptions{SaltLength: saltLength, Hash: hash, MGFHash: getMGFHash(mgfHashName)})
+	case schemeFDH:
+		signature, err = simplersa.SignFDH(rng, priv, hash, digest[:])
+	case schemeISO9796Scheme1, schemeISO9796Scheme2:
+		// M2 is the tail of the message, Verify splits it off again
+		opts := &simplersa.ISO9796Options{Scheme: scheme - schemeFDH, SaltLength: saltLength}
+		signature, _, err = simplersa.SignISO9796(rng, priv, hash, msg, opts)
+	default:
 		signature, err = simplersa.SignPKCS1v15(rng, priv, hash, digest[:])
 	}
 	if err != nil {
@@ -192,7 +578,7 @@ func Sign(plaintext string, hashName string, isUsePSS bool, saltLength int) stri
 	return fmt.Sprintf("%x", signature)
 }
 
-func Verify(plaintext string, signature string, hashName string, isUsePSS bool, saltLength int) string {
+func Verify(plaintext string, signature string, hashName string, scheme int, saltLength int, mgfHashName string) string {
 	if priv == nil {
 		return ErrNoKey
 	}
@@ -202,18 +588,38 @@ func Verify(plaintext string, signature string, hashName string, isUsePSS bool,
 	if err != nil {
 		return VerifyFalse
 	}
-	//fmt.Println("Verify: hashName", hashName, hash.String(), "isUsePSS", isUsePSS, "saltLength", saltLength)
+	//fmt.Println("Verify: hashName", hashName, hash.String(), "scheme", scheme, "saltLength", saltLength)
+
+	if x := getSHAKE(hashName); x != 0 {
+		if scheme != schemePSS || simplersa.VerifyPSSWithSHAKE(&priv.PublicKey, x, x.Sum(msg), sig) != nil {
+			return VerifyFalse
+		}
+		return VerifyTrue
+	}
 
 	hashFunc := hash.New()
 	hashFunc.Write(msg)
 	digest := hashFunc.Sum(nil)
 
-	if isUsePSS {
+	switch scheme {
+	case schemePSS:
 		if saltLength < -1 {
 			saltLength = 0
 		}
-		err = simplersa.VerifyPSS(&priv.PublicKey, hash, digest[:], sig, &simplersa.PSSOptions{SaltLength: saltLength, Hash: hash})
-	} else {
+		err = simplersa.VerifyPSS(&priv.PublicKey, hash, digest[:], sig, &simplersa.PSSOptions{SaltLength: saltLength, Hash: hash, MGFHash: getMGFHash(mgfHashName)})
+	case schemeFDH:
+		err = simplersa.VerifyFDH(&priv.PublicKey, hash, digest[:], sig)
+	case schemeISO9796Scheme1, schemeISO9796Scheme2:
+		opts := &simplersa.ISO9796Options{Scheme: scheme - schemeFDH, SaltLength: saltLength}
+		var m2, recovered []byte
+		if c := simplersa.ISO9796Capacity(&priv.PublicKey, hash, opts); len(msg) > c {
+			m2 = msg[c:]
+		}
+		recovered, err = simplersa.VerifyISO9796(&priv.PublicKey, hash, sig, m2, opts)
+		if err == nil {
+			return fmt.Sprintf("%s\nRecovered M1: %q", VerifyTrue, recovered[:len(recovered)-len(m2)])
+		}
+	default:
 		err = simplersa.VerifyPKCS1v15(&priv.PublicKey, hash, digest[:], sig)
 	}
 	if err != nil {
@@ -224,7 +630,7 @@ func Verify(plaintext string, signature string, hashName string, isUsePSS bool,
 
 func ChangeParallel(state bool) {
 	log.Println("Parallel Mode:", state)
-	simplersa.ParaCalc = state
+	key_parallel = state
 }
 
 func main() {
@@ -258,6 +664,16 @@ func main() {
 	ui.Bind("sign", Sign)
 	ui.Bind("verify", Verify)
 	ui.Bind("changeParallel", ChangeParallel)
+	ui.Bind("auditKey", AuditKey)
+	ui.Bind("factorMethodNames", FactorMethodNames)
+	ui.Bind("factorKey", FactorKey)
+	ui.Bind("runAttackDemo", RunAttackDemo)
+	ui.Bind("blindVariantNames", BlindVariantNames)
+	ui.Bind("blindMessage", BlindMessage)
+	ui.Bind("signBlinded", SignBlinded)
+	ui.Bind("finalizeBlind", FinalizeBlind)
+	ui.Bind("exportKeyShares", ExportKeyShares)
+	ui.Bind("restoreKeyShares", RestoreKeyShares)
 
 	// Load HTML.
 	// You may also use `data:text/html,<base64>` approach to load initial HTML,
@@ -272,7 +688,7 @@ func main() {
 	ui.Load(fmt.Sprintf("http://%s/www", ln.Addr()))
 
 	// Wait until the interrupt signal arrives or browser window is closed
-	sigc := make(chan os.Signal)
+	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, os.Interrupt)
 	select {
 	case <-sigc: